@@ -17,6 +17,8 @@ type appConfig struct {
 	GoogleCustomCfg *GoogleCustomConfig  `toml:"google_custom"`
 	CacheCfg        *CacheConfig         `toml:"cache"`
 	SummaryLLMCfg   *SummaryLLMConfig    `toml:"summarize"`
+	APIKeysCfg      []APIKeyConfig       `toml:"api_keys"`
+	OAuthCfg        *OAuthConfig         `toml:"oauth"`
 }
 
 type MCPFurlConfig struct {
@@ -24,6 +26,12 @@ type MCPFurlConfig struct {
 	// WebDriverPath *string `toml:"web_driver_path"`
 	// WebDriverLog  *string `toml:"web_driver_log"`
 	UsePandoc      *bool    `toml:"use_pandoc"`
+	FetchMode      *string  `toml:"fetch_mode"`
+	Extraction     *string  `toml:"extraction_mode"`
+	FlattenFrames  *bool    `toml:"flatten_frames"`
+	FailOnExcept   *bool    `toml:"fail_on_exceptions"`
+	Block          []string `toml:"block"`
+	TrackerList    *string  `toml:"tracker_blocklist"`
 	SearchEngine   *string  `toml:"search_engine"`
 	Verbose        *bool    `toml:"verbose"`
 	FetchDesc      *string  `toml:"fetch_tool_desc"`
@@ -37,15 +45,83 @@ type MCPFurlConfig struct {
 	DisableSummary *bool    `toml:"disable_summary"`
 	Allow          []string `toml:"allow"`
 	Deny           []string `toml:"deny"`
+	MaxTabs        *int     `toml:"max_tabs"`
+	TabQueueWait   *string  `toml:"tab_queue_timeout"`
+	RecycleTabs    *int     `toml:"browser_recycle_tabs"`
+	RecycleAfter   *string  `toml:"browser_recycle_after"`
+	BrowserWSURL   *string  `toml:"browser_ws_url"`
+	Proxy          *string  `toml:"proxy"`
+	ProxyBypass    []string `toml:"proxy_bypass"`
+	Robots         *string  `toml:"robots"`
+	RobotsAgent    *string  `toml:"robots_user_agent"`
+	BlockPrivate   *bool    `toml:"block_private_ips"`
+	AllowNetworks  []string `toml:"allow_networks"`
+	PolicyDefault  *string  `toml:"policy_default"`
 
 	// Note: these are only configurable through config.toml, no cmdline arguments
 	SelectorCfg []UrlSelectorConfig `toml:"selectors"`
 	CrawlCfg    []CrawlConfig       `toml:"crawl"`
+	ProfileCfg  []ProfileConfig     `toml:"profiles"`
+	ProxyRules  []ProxyRuleConfig   `toml:"proxy_rules"`
+	Policy      []PolicyRuleConfig  `toml:"policy"`
+}
+
+type ProxyRuleConfig struct {
+	Url   *string `toml:"url"`
+	Proxy *string `toml:"proxy"`
+}
+
+// PolicyRuleConfig is one [[mcpfurl.policy]] rule. Rules are checked in
+// order, before the allow/deny globs.
+type PolicyRuleConfig struct {
+	Name       *string  `toml:"name"`
+	Action     *string  `toml:"action"` // allow, deny or require_auth
+	Scheme     []string `toml:"scheme"`
+	Host       []string `toml:"host"`
+	Port       []string `toml:"port"` // ex: "443" or "8000-8999"
+	PathPrefix []string `toml:"path_prefix"`
+	Glob       []string `toml:"glob"`
+	Regex      *string  `toml:"regex"`
+	CIDR       []string `toml:"cidr"`
 }
 
 type UrlSelectorConfig struct {
-	Url      *string `toml:"url"`
+	Url      *string  `toml:"url"`
+	Selector *string  `toml:"selector"`
+	Wait     *string  `toml:"wait"`
+	Exclude  []string `toml:"exclude"`
+}
+
+type ProfileConfig struct {
+	Url            *string           `toml:"url"`
+	Headers        map[string]string `toml:"headers"`
+	Cookies        []CookieConfig    `toml:"cookies"`
+	AcceptLanguage *string           `toml:"accept_language"`
+	UserAgent      *string           `toml:"user_agent"`
+	ViewportWidth  *int              `toml:"viewport_width"`
+	ViewportHeight *int              `toml:"viewport_height"`
+	Device         *string           `toml:"device"`
+	JavaScript     *bool             `toml:"javascript"`
+	Wait           *string           `toml:"wait"`
+	Actions        []ActionConfig    `toml:"actions"`
+	Block          []string          `toml:"block"`
+}
+
+type CookieConfig struct {
+	Name   *string `toml:"name"`
+	Value  *string `toml:"value"`
+	Domain *string `toml:"domain"`
+	Path   *string `toml:"path"`
+}
+
+type ActionConfig struct {
+	Action   *string `toml:"action"`
 	Selector *string `toml:"selector"`
+	Text     *string `toml:"text"`
+	Key      *string `toml:"key"`
+	Times    *int    `toml:"times"`
+	Duration *string `toml:"duration"`
+	Script   *string `toml:"script"`
 }
 
 type CrawlConfig struct {
@@ -62,6 +138,29 @@ type MCPHTTPServerConfig struct {
 	EnableRestAPI *bool   `toml:"enable_rest_api"`
 }
 
+// APIKeyConfig is one [[api_keys]] entry of the HTTP server.
+type APIKeyConfig struct {
+	Name           *string  `toml:"name"`
+	KeySHA256      *string  `toml:"key_sha256"` // see mcpfurl hash-key
+	Tools          []string `toml:"tools"`
+	Allow          []string `toml:"allow"`
+	Deny           []string `toml:"deny"`
+	RequestsPerDay *int     `toml:"requests_per_day"`
+	BytesPerDay    *int64   `toml:"bytes_per_day"`
+}
+
+// OAuthConfig makes the HTTP server accept JWT access tokens from an OAuth
+// authorization server.
+type OAuthConfig struct {
+	Issuer               *string             `toml:"issuer"`
+	Audience             *string             `toml:"audience"`
+	JWKS                 *string             `toml:"jwks"` // file or URL
+	Resource             *string             `toml:"resource"`
+	AuthorizationServers []string            `toml:"authorization_servers"`
+	NameClaim            *string             `toml:"name_claim"`
+	Scopes               map[string][]string `toml:"scopes"` // scope -> tools
+}
+
 type SummaryLLMConfig struct {
 	BaseURL *string `toml:"base_url"`
 	ApiKey  *string `toml:"api_key"`
@@ -137,6 +236,8 @@ func applyMCPHTTPConfig(cmd *cobra.Command) {
 		applyHTTPMasterKeyEnv(cmd)
 		return
 	}
+	applyAPIKeysConfig()
+	applyOAuthConfig()
 
 	if cfg := userConfig.HTTPCfg; cfg != nil {
 		if cfg.Addr != nil && !cmd.Flags().Changed("addr") {
@@ -180,6 +281,28 @@ func applyCommonConfig(cmd *cobra.Command, cfg *MCPFurlConfig) {
 	if cfg.UsePandoc != nil && !cmd.Flags().Changed("pandoc") {
 		usePandoc = *cfg.UsePandoc
 	}
+	if cfg.FetchMode != nil && !cmd.Flags().Changed("fetch-mode") {
+		fetchMode = *cfg.FetchMode
+	}
+	if cfg.Extraction != nil && !cmd.Flags().Changed("extraction") {
+		extractionMode = *cfg.Extraction
+	}
+	if cfg.FlattenFrames != nil && !cmd.Flags().Changed("flatten-frames") {
+		flattenFrames = *cfg.FlattenFrames
+	}
+	if cfg.FailOnExcept != nil && !cmd.Flags().Changed("fail-on-exceptions") {
+		failOnExceptions = *cfg.FailOnExcept
+	}
+	if cfg.Block != nil && !cmd.Flags().Changed("block") {
+		blockList = cfg.Block
+	}
+	if cfg.TrackerList != nil && *cfg.TrackerList != "" {
+		patterns, err := fetchurl.LoadTrackerBlocklist(*cfg.TrackerList)
+		if err != nil {
+			log.Fatalf("Unable to load tracker_blocklist: %v", err)
+		}
+		trackerBlocklist = patterns
+	}
 	if cfg.DisableFetch != nil && !cmd.Flags().Changed("disable-fetch") {
 		disableFetch = *cfg.DisableFetch
 	}
@@ -221,13 +344,197 @@ func applyCommonConfig(cmd *cobra.Command, cfg *MCPFurlConfig) {
 	if len(cfg.Deny) > 0 && !cmd.Flags().Changed("disallow") {
 		httpDenyGlobs = normalizePatterns(cfg.Deny)
 	}
+	if cfg.MaxTabs != nil && !cmd.Flags().Changed("max-tabs") {
+		maxTabs = *cfg.MaxTabs
+	}
+	if cfg.TabQueueWait != nil && !cmd.Flags().Changed("tab-queue-timeout") {
+		d, err := fetchurl.ConvertTTLToDuration(*cfg.TabQueueWait)
+		if err != nil {
+			log.Fatalf("Unable to parse tab_queue_timeout value: %s", *cfg.TabQueueWait)
+		}
+		tabQueueTimeout = d
+	}
+	if cfg.RecycleTabs != nil {
+		browserRecycleTabs = *cfg.RecycleTabs
+	}
+	if cfg.BrowserWSURL != nil && !cmd.Flags().Changed("browser-ws-url") {
+		browserWSURL = *cfg.BrowserWSURL
+	}
+	if cfg.Proxy != nil && !cmd.Flags().Changed("proxy") {
+		proxyURL = *cfg.Proxy
+	}
+	if cfg.ProxyBypass != nil && !cmd.Flags().Changed("proxy-bypass") {
+		proxyBypass = cfg.ProxyBypass
+	}
+	if cfg.Robots != nil && !cmd.Flags().Changed("robots") {
+		robotsMode = *cfg.Robots
+	}
+	if cfg.RobotsAgent != nil && !cmd.Flags().Changed("robots-user-agent") {
+		robotsUserAgent = *cfg.RobotsAgent
+	}
+	if cfg.BlockPrivate != nil && !cmd.Flags().Changed("block-private-ips") {
+		blockPrivateIPs = *cfg.BlockPrivate
+	}
+	if cfg.AllowNetworks != nil && !cmd.Flags().Changed("allow-networks") {
+		allowNetworks = cfg.AllowNetworks
+	}
+	if cfg.PolicyDefault != nil {
+		policyDefault = *cfg.PolicyDefault
+	}
+	if cfg.RecycleAfter != nil {
+		d, err := fetchurl.ConvertTTLToDuration(*cfg.RecycleAfter)
+		if err != nil {
+			log.Fatalf("Unable to parse browser_recycle_after value: %s", *cfg.RecycleAfter)
+		}
+		browserRecycleAfter = d
+	}
 
 	if len(cfg.SelectorCfg) > 0 {
 		selectors = []fetchurl.UrlSelector{}
 		for _, s := range cfg.SelectorCfg {
-			if s.Url != nil && s.Selector != nil {
-				selectors = append(selectors, fetchurl.UrlSelector{Url: *s.Url, Selector: *s.Selector})
+			if s.Url == nil || (s.Selector == nil && s.Wait == nil && len(s.Exclude) == 0) {
+				continue
+			}
+			sel := fetchurl.UrlSelector{Url: *s.Url}
+			if s.Selector != nil {
+				sel.Selector = *s.Selector
 			}
+			if s.Wait != nil {
+				wait, err := fetchurl.ParseWaitStrategy(*s.Wait)
+				if err != nil {
+					log.Fatalf("Invalid wait value for selector %s: %v", *s.Url, err)
+				}
+				sel.Wait = wait
+			}
+			if len(s.Exclude) > 0 {
+				if err := fetchurl.ValidateExcludeSelectors(s.Exclude); err != nil {
+					log.Fatalf("Invalid exclude value for selector %s: %v", *s.Url, err)
+				}
+				sel.Exclude = s.Exclude
+			}
+			selectors = append(selectors, sel)
+		}
+	}
+
+	if len(cfg.ProfileCfg) > 0 {
+		profiles = []fetchurl.FetchProfile{}
+		for _, p := range cfg.ProfileCfg {
+			if p.Url == nil {
+				continue
+			}
+			profile := fetchurl.FetchProfile{Url: *p.Url, Headers: p.Headers}
+			for _, c := range p.Cookies {
+				if c.Name == nil || c.Value == nil {
+					log.Fatalf("Profile %s: cookies need both a name and a value", *p.Url)
+				}
+				cookie := fetchurl.ProfileCookie{Name: *c.Name, Value: *c.Value}
+				if c.Domain != nil {
+					cookie.Domain = *c.Domain
+				}
+				if c.Path != nil {
+					cookie.Path = *c.Path
+				}
+				profile.Cookies = append(profile.Cookies, cookie)
+			}
+			if p.AcceptLanguage != nil {
+				profile.AcceptLanguage = *p.AcceptLanguage
+			}
+			if p.UserAgent != nil {
+				profile.UserAgent = *p.UserAgent
+			}
+			if p.ViewportWidth != nil {
+				profile.ViewportWidth = *p.ViewportWidth
+			}
+			if p.ViewportHeight != nil {
+				profile.ViewportHeight = *p.ViewportHeight
+			}
+			if p.Device != nil {
+				if _, err := fetchurl.LookupDevice(*p.Device); err != nil {
+					log.Fatalf("Profile %s: %v", *p.Url, err)
+				}
+				profile.Device = *p.Device
+			}
+			if p.JavaScript != nil {
+				profile.DisableJavaScript = !*p.JavaScript
+			}
+			if p.Wait != nil {
+				wait, err := fetchurl.ParseWaitStrategy(*p.Wait)
+				if err != nil {
+					log.Fatalf("Invalid wait value for profile %s: %v", *p.Url, err)
+				}
+				profile.Wait = wait
+			}
+			for _, a := range p.Actions {
+				action := fetchurl.PageAction{}
+				if a.Action != nil {
+					action.Action = *a.Action
+				}
+				if a.Selector != nil {
+					action.Selector = *a.Selector
+				}
+				if a.Text != nil {
+					action.Text = *a.Text
+				}
+				if a.Key != nil {
+					action.Key = *a.Key
+				}
+				if a.Times != nil {
+					action.Times = *a.Times
+				}
+				if a.Duration != nil {
+					action.Duration = *a.Duration
+				}
+				if a.Script != nil {
+					action.Script = *a.Script
+				}
+				profile.Actions = append(profile.Actions, action)
+			}
+			if err := fetchurl.ValidatePageActions(profile.Actions); err != nil {
+				log.Fatalf("Invalid actions for profile %s: %v", *p.Url, err)
+			}
+			if p.Block != nil {
+				blocking, err := fetchurl.ParseResourceBlocking(p.Block)
+				if err != nil {
+					log.Fatalf("Invalid block value for profile %s: %v", *p.Url, err)
+				}
+				profile.Blocking = &blocking
+			}
+			profiles = append(profiles, profile)
+		}
+	}
+
+	if len(cfg.ProxyRules) > 0 {
+		proxyRules = nil
+		for _, r := range cfg.ProxyRules {
+			if r.Url == nil || r.Proxy == nil {
+				log.Fatalf("proxy_rules entries need both a url and a proxy")
+			}
+			proxyRules = append(proxyRules, fetchurl.ProxyRule{URL: *r.Url, Proxy: *r.Proxy})
+		}
+	}
+
+	if len(cfg.Policy) > 0 {
+		policyRules = nil
+		for _, r := range cfg.Policy {
+			if r.Action == nil {
+				log.Fatalf("policy rules need an action (allow, deny or require_auth)")
+			}
+			rule := fetchurl.PolicyRule{
+				Action:       *r.Action,
+				Schemes:      r.Scheme,
+				Hosts:        r.Host,
+				Ports:        r.Port,
+				PathPrefixes: r.PathPrefix,
+				Globs:        r.Glob,
+				CIDRs:        r.CIDR,
+			}
+			if r.Name != nil {
+				rule.Name = *r.Name
+			}
+			if r.Regex != nil {
+				rule.Regex = *r.Regex
+			}
+			policyRules = append(policyRules, rule)
 		}
 	}
 
@@ -264,6 +571,21 @@ func applyCommonConfig(cmd *cobra.Command, cfg *MCPFurlConfig) {
 	}
 }
 
+// proxyConfig returns the --proxy and --proxy-bypass flags (or proxy,
+// proxy_bypass and proxy_rules in config.toml).
+func proxyConfig() fetchurl.ProxyConfig {
+	return fetchurl.ProxyConfig{URL: proxyURL, Bypass: proxyBypass, Rules: proxyRules}
+}
+
+// parseBlockList parses the --block flag (or block in config.toml).
+func parseBlockList() fetchurl.ResourceBlocking {
+	blocking, err := fetchurl.ParseResourceBlocking(blockList)
+	if err != nil {
+		log.Fatalf("Invalid block value: %v", err)
+	}
+	return blocking
+}
+
 func applyGoogleCustomConfig(cmd *cobra.Command) {
 	if userConfig == nil || userConfig.GoogleCustomCfg == nil {
 		return
@@ -320,6 +642,57 @@ func applyCacheConfig(cmd *cobra.Command) {
 	}
 }
 
+func applyAPIKeysConfig() {
+	if len(userConfig.APIKeysCfg) == 0 {
+		return
+	}
+	apiKeys = nil
+	for _, k := range userConfig.APIKeysCfg {
+		if k.Name == nil || k.KeySHA256 == nil {
+			log.Fatalf("api_keys entries need both a name and a key_sha256")
+		}
+		key := mcpserver.APIKey{
+			Name:            *k.Name,
+			SHA256:          *k.KeySHA256,
+			Tools:           k.Tools,
+			AllowedURLGlobs: normalizePatterns(k.Allow),
+			DenyURLGlobs:    normalizePatterns(k.Deny),
+		}
+		if k.RequestsPerDay != nil {
+			key.RequestsPerDay = *k.RequestsPerDay
+		}
+		if k.BytesPerDay != nil {
+			key.BytesPerDay = *k.BytesPerDay
+		}
+		apiKeys = append(apiKeys, key)
+	}
+}
+
+func applyOAuthConfig() {
+	cfg := userConfig.OAuthCfg
+	if cfg == nil {
+		return
+	}
+	if cfg.Issuer == nil || cfg.JWKS == nil {
+		log.Fatalf("oauth needs both an issuer and a jwks")
+	}
+	oauthOpts = &mcpserver.OAuthOptions{
+		Issuer:               *cfg.Issuer,
+		JWKS:                 *cfg.JWKS,
+		AuthorizationServers: normalizePatterns(cfg.AuthorizationServers),
+		Scopes:               cfg.Scopes,
+	}
+	if cfg.Audience != nil {
+		oauthOpts.Audience = *cfg.Audience
+	}
+	if cfg.Resource != nil {
+		oauthOpts.Resource = *cfg.Resource
+	}
+	if cfg.NameClaim != nil {
+		oauthOpts.NameClaim = *cfg.NameClaim
+	}
+}
+
 func applyHTTPMasterKeyEnv(cmd *cobra.Command) {
 	if cmd.Flags().Changed("master-key") {
 		return
@@ -46,11 +46,26 @@ var crawlCmd = &cobra.Command{
 			ConvertAbsoluteHref: useAbsHref,
 			Logger:              logger,
 			UsePandoc:           usePandoc,
+			FetchMode:           fetchMode,
+			ExtractionMode:      extractionMode,
+			FlattenFrames:       flattenFrames,
+			FailOnExceptions:    failOnExceptions,
+			Blocking:            parseBlockList(),
+			Proxy:               proxyConfig(),
+			RobotsMode:          robotsMode,
+			RobotsUserAgent:     robotsUserAgent,
+			BlockPrivateIPs:     blockPrivateIPs,
+			AllowedNetworks:     allowNetworks,
+			TrackerBlocklist:    trackerBlocklist,
+			BrowserWSURL:        browserWSURL,
 			CachePath:           cachePath,
 			CacheExpires:        cacheExpires,
 			AllowedURLGlobs:     httpAllowGlobs,
 			DenyURLGlobs:        httpDenyGlobs,
+			PolicyRules:         policyRules,
+			PolicyDefault:       policyDefault,
 			UrlSelectors:        selectors,
+			Profiles:            profiles,
 		})
 		if err != nil {
 			log.Fatalf("ERROR: %v\n", err)
@@ -60,7 +75,8 @@ var crawlCmd = &cobra.Command{
 		}
 		defer fetcher.Stop()
 
-		ctx := context.Background()
+		// the command line is the operator's, who may fetch require_auth URLs
+		ctx := fetchurl.WithAuthenticatedCaller(context.Background())
 		ctx, cancel := context.WithTimeout(ctx, time.Duration(maxCrawlSeconds)*time.Second)
 		defer cancel()
 
@@ -103,6 +119,17 @@ func init() {
 	crawlCmd.Flags().BoolVar(&convertToMarkdown2, "md", false, "Alias for --markdown")
 	crawlCmd.Flags().BoolVarP(&convertToMarkdown, "markdown", "m", false, "Convert HTML to Markdown")
 	crawlCmd.Flags().BoolVar(&usePandoc, "pandoc", false, "Convert HTML to Markdown using pandoc")
+	crawlCmd.Flags().StringVar(&extractionMode, "extraction", fetchurl.ExtractionSelector, "Content extraction: selector (matching [[selectors]] entry, else the whole body), body (always the whole body) or readability (matching selector, else the main content only)")
+	crawlCmd.Flags().BoolVar(&flattenFrames, "flatten-frames", false, "Inline the content of iframes and open shadow roots when fetching with a browser")
+	crawlCmd.Flags().BoolVar(&failOnExceptions, "fail-on-exceptions", false, "Fail browser fetches of pages that throw uncaught JS exceptions before they are ready")
+	crawlCmd.Flags().StringSliceVar(&blockList, "block", nil, "Requests to block when fetching with a browser: resource types (image, media, font, stylesheet, script, ...), trackers and/or policy (apply allow/deny to sub-resources)")
+	crawlCmd.Flags().StringVar(&fetchMode, "fetch-mode", fetchurl.FetchModeBrowser, "How to fetch pages: browser, http (no Chrome) or auto (http, falling back to browser)")
+	crawlCmd.Flags().StringVar(&proxyURL, "proxy", "", "Proxy for outbound requests: http://, https://, socks5:// or socks5h://, with optional user:password@")
+	crawlCmd.Flags().StringSliceVar(&proxyBypass, "proxy-bypass", nil, "Hosts that don't use the proxy: domains, host globs or IP ranges")
+	crawlCmd.Flags().StringVar(&robotsMode, "robots", fetchurl.RobotsOff, "Respect robots.txt: off, crawl (pages visited by crawls) or all (every fetch)")
+	crawlCmd.Flags().StringVar(&robotsUserAgent, "robots-user-agent", fetchurl.DefaultRobotsUserAgent, "User agent matched against robots.txt rules")
+	crawlCmd.Flags().BoolVar(&blockPrivateIPs, "block-private-ips", false, "Refuse URLs that resolve to loopback, private, link-local or other reserved addresses")
+	crawlCmd.Flags().StringSliceVar(&allowNetworks, "allow-networks", nil, "Networks (CIDRs) exempt from --block-private-ips")
 	crawlCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Verbose output")
 	crawlCmd.Flags().IntVar(&maxCrawlPages, "max-pages", 20, "Maximum pages to crawl")
 	crawlCmd.Flags().IntVar(&maxCrawlDepth, "max-depth", 2, "Maximum crawl depth (root=0)")
@@ -58,13 +58,28 @@ var fetchCmd = &cobra.Command{
 			// WebDriverPort:       webDriverPort,
 			// ChromeDriverPath:    webDriverPath,
 			// WebDriverLogging:    webDriverLog,
-			Logger:          logger,
-			UsePandoc:       usePandoc,
-			CachePath:       cachePath,
-			CacheExpires:    cacheExpires,
-			AllowedURLGlobs: httpAllowGlobs,
-			DenyURLGlobs:    httpDenyGlobs,
-			UrlSelectors:    selectors,
+			Logger:           logger,
+			UsePandoc:        usePandoc,
+			FetchMode:        fetchMode,
+			ExtractionMode:   extractionMode,
+			FlattenFrames:    flattenFrames,
+			FailOnExceptions: failOnExceptions,
+			Blocking:         parseBlockList(),
+			Proxy:            proxyConfig(),
+			RobotsMode:       robotsMode,
+			RobotsUserAgent:  robotsUserAgent,
+			BlockPrivateIPs:  blockPrivateIPs,
+			AllowedNetworks:  allowNetworks,
+			TrackerBlocklist: trackerBlocklist,
+			BrowserWSURL:     browserWSURL,
+			CachePath:        cachePath,
+			CacheExpires:     cacheExpires,
+			AllowedURLGlobs:  httpAllowGlobs,
+			DenyURLGlobs:     httpDenyGlobs,
+			PolicyRules:      policyRules,
+			PolicyDefault:    policyDefault,
+			UrlSelectors:     selectors,
+			Profiles:         profiles,
 		})
 		if err != nil {
 			log.Fatalf("ERROR: %v\n", err)
@@ -87,9 +102,44 @@ var fetchCmd = &cobra.Command{
 		// }
 		// webpage := res.Page
 
-		ctx := context.Background()
-		if outputPNG == "" {
-			webpage, err := fetcher.FetchURL(ctx, url, selector)
+		wait, err := fetchurl.ParseWaitStrategy(fetchWait)
+		if err != nil {
+			log.Fatalf("ERROR: %v\n", err)
+		}
+		actions, err := fetchurl.ParsePageActions(fetchActions)
+		if err != nil {
+			log.Fatalf("ERROR: %v\n", err)
+		}
+		if err := fetchurl.ValidateExcludeSelectors(fetchExclude); err != nil {
+			log.Fatalf("ERROR: %v\n", err)
+		}
+		if err := printOpts.Validate(); err != nil {
+			log.Fatalf("ERROR: %v\n", err)
+		}
+
+		// the command line is the operator's, who may fetch require_auth URLs
+		ctx := fetchurl.WithAuthenticatedCaller(context.Background())
+		if outputPDF != "" {
+			data, err := fetcher.FetchURLPDF(ctx, url, printOpts)
+			if err != nil {
+				log.Fatalf("ERROR: %v\n", err)
+			}
+
+			if err := os.WriteFile(outputPDF, data, 0644); err != nil {
+				log.Fatalf("ERROR: %v\n", err)
+			}
+		} else if outputPNG == "" {
+			fetchOpts := fetchurl.FetchOptions{Selector: selector, Wait: wait, Actions: actions, Exclude: fetchExclude, AllowErrorPages: fetchAllowErrorPages}
+			if outputHAR != "" {
+				fetchOpts.HAR = fetchurl.NewHARRecorder(harBodyLimit)
+			}
+			webpage, err := fetcher.FetchURLWithOptions(ctx, url, fetchOpts)
+			// the HAR is written even if the fetch failed, to see why
+			if fetchOpts.HAR != nil {
+				if err := fetchOpts.HAR.WriteFile(outputHAR); err != nil {
+					log.Fatalf("ERROR: %v\n", err)
+				}
+			}
 			if err != nil {
 				log.Fatalf("ERROR: %v\n", err)
 			}
@@ -101,6 +151,8 @@ var fetchCmd = &cobra.Command{
 					} else {
 						fmt.Println(err)
 					}
+				} else if webpage.Content != "" {
+					fmt.Println(webpage.Content)
 				} else {
 					fmt.Println(webpage.Src)
 				}
@@ -127,6 +179,14 @@ var convertToMarkdown2 bool
 var useAbsHref bool
 var verbose bool
 var outputPNG string
+var outputPDF string
+var outputHAR string
+var harBodyLimit int
+var printOpts fetchurl.PrintOptions
+var fetchWait string
+var fetchActions string
+var fetchExclude []string
+var fetchAllowErrorPages bool
 
 // var webDriverPort int
 // var webDriverPath string
@@ -141,7 +201,32 @@ func init() {
 	fetchCmd.Flags().BoolVar(&convertToMarkdown2, "md", false, "Alias for --markdown")
 	fetchCmd.Flags().BoolVarP(&convertToMarkdown, "markdown", "m", false, "Convert HTML to Markdown")
 	fetchCmd.Flags().BoolVar(&usePandoc, "pandoc", false, "Convert HTML to Markdown using pandoc")
+	fetchCmd.Flags().StringVar(&browserWSURL, "browser-ws-url", "", "Attach to a running Chrome at this DevTools URL instead of launching one")
+	fetchCmd.Flags().StringVar(&extractionMode, "extraction", fetchurl.ExtractionSelector, "Content extraction: selector (matching [[selectors]] entry, else the whole body), body (always the whole body) or readability (matching selector, else the main content only)")
+	fetchCmd.Flags().BoolVar(&flattenFrames, "flatten-frames", false, "Inline the content of iframes and open shadow roots when fetching with a browser")
+	fetchCmd.Flags().BoolVar(&failOnExceptions, "fail-on-exceptions", false, "Fail browser fetches of pages that throw uncaught JS exceptions before they are ready")
+	fetchCmd.Flags().StringSliceVar(&blockList, "block", nil, "Requests to block when fetching with a browser: resource types (image, media, font, stylesheet, script, ...), trackers and/or policy (apply allow/deny to sub-resources)")
+	fetchCmd.Flags().StringVar(&fetchMode, "fetch-mode", fetchurl.FetchModeBrowser, "How to fetch pages: browser, http (no Chrome) or auto (http, falling back to browser)")
+	fetchCmd.Flags().StringVar(&proxyURL, "proxy", "", "Proxy for outbound requests: http://, https://, socks5:// or socks5h://, with optional user:password@")
+	fetchCmd.Flags().StringSliceVar(&proxyBypass, "proxy-bypass", nil, "Hosts that don't use the proxy: domains, host globs or IP ranges")
+	fetchCmd.Flags().StringVar(&robotsMode, "robots", fetchurl.RobotsOff, "Respect robots.txt: off, crawl (pages visited by crawls) or all (every fetch)")
+	fetchCmd.Flags().StringVar(&robotsUserAgent, "robots-user-agent", fetchurl.DefaultRobotsUserAgent, "User agent matched against robots.txt rules")
+	fetchCmd.Flags().BoolVar(&blockPrivateIPs, "block-private-ips", false, "Refuse URLs that resolve to loopback, private, link-local or other reserved addresses")
+	fetchCmd.Flags().StringSliceVar(&allowNetworks, "allow-networks", nil, "Networks (CIDRs) exempt from --block-private-ips")
 	fetchCmd.Flags().StringVar(&outputPNG, "png", "", "Output screenshot to PNG file")
+	fetchCmd.Flags().StringVar(&outputPDF, "pdf", "", "Print the page to a PDF file")
+	fetchCmd.Flags().StringVar(&outputHAR, "har", "", "Record the page's network activity to a HAR file")
+	fetchCmd.Flags().IntVar(&harBodyLimit, "har-body-limit", fetchurl.DefaultHARBodyLimit, "Largest response body to keep in the HAR file, in bytes (-1 for none)")
+	fetchCmd.Flags().StringVar(&printOpts.PaperSize, "paper", "letter", "PDF paper size: letter, legal, tabloid, ledger, a3, a4 or a5")
+	fetchCmd.Flags().BoolVar(&printOpts.Landscape, "landscape", false, "Print the PDF in landscape orientation")
+	fetchCmd.Flags().StringVar(&printOpts.Margins, "margins", "", "PDF margins in inches: one value, or top,right,bottom,left")
+	fetchCmd.Flags().BoolVar(&printOpts.PrintBackground, "print-background", false, "Include background graphics in the PDF")
+	fetchCmd.Flags().StringVar(&printOpts.HeaderTemplate, "header-template", "", "HTML template for the PDF page header")
+	fetchCmd.Flags().StringVar(&printOpts.FooterTemplate, "footer-template", "", "HTML template for the PDF page footer (ex: '<span class=pageNumber></span>')")
+	fetchCmd.Flags().StringVar(&fetchWait, "wait", "", "Page readiness strategy (load, network-idle[:dur], selector:<css>, js:<expr>, delay:<dur>, dom-quiet[:dur])")
+	fetchCmd.Flags().BoolVar(&fetchAllowErrorPages, "allow-error-pages", false, "Return pages served with a 4xx or 5xx status instead of failing")
+	fetchCmd.Flags().StringArrayVar(&fetchExclude, "exclude", nil, "CSS selector of elements to remove before conversion (repeatable)")
+	fetchCmd.Flags().StringVar(&fetchActions, "actions", "", `JSON list of page actions to run before capture (ex: '[{"action":"click","selector":"#more"}]')`)
 	fetchCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Verbose output")
 	fetchCmd.Flags().MarkHidden("md")
 
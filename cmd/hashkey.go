@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"log"
+
+	"github.com/mbreese/mcpfurl/mcpserver"
+	"github.com/spf13/cobra"
+)
+
+var hashKeyCmd = &cobra.Command{
+	Use:   "hash-key [key]",
+	Short: "Print the key_sha256 of an API key (a new random key if none is given)",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var key string
+		if len(args) > 0 {
+			key = args[0]
+		} else {
+			buf := make([]byte, 32)
+			if _, err := rand.Read(buf); err != nil {
+				log.Fatalf("error generating a key: %v", err)
+			}
+			key = base64.RawURLEncoding.EncodeToString(buf)
+			fmt.Printf("key        : %s\n", key)
+		}
+		fmt.Printf("key_sha256 : %s\n", mcpserver.HashAPIKey(key))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(hashKeyCmd)
+}
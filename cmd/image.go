@@ -38,12 +38,15 @@ var fetchImgCmd = &cobra.Command{
 			MaxDownloadBytes: fetchImgMaxBytes,
 			AllowedURLGlobs:  httpAllowGlobs,
 			DenyURLGlobs:     httpDenyGlobs,
+			PolicyRules:      policyRules,
+			PolicyDefault:    policyDefault,
 		})
 		if err != nil {
 			log.Fatalf("error setting up webfetcher %s: %v", url, err)
 		}
 
-		ctx := context.Background()
+		// the command line is the operator's, who may fetch require_auth URLs
+		ctx := fetchurl.WithAuthenticatedCaller(context.Background())
 		resource, err := fetcher.DownloadResource(ctx, url)
 		if err != nil {
 			log.Fatalf("error downloading %s: %v", url, err)
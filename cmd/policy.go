@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/mbreese/mcpfurl/fetchurl"
+	"github.com/spf13/cobra"
+)
+
+var policyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Inspect the URL policy",
+}
+
+var policyTestCmd = &cobra.Command{
+	Use:   "test <url>",
+	Short: "Show how the configured URL policy treats a URL, and which rule decided",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		applyMCPConfig(cmd)
+
+		policy, err := fetchurl.NewURLPolicy(policyRules, httpAllowGlobs, httpDenyGlobs, policyDefault)
+		if err != nil {
+			log.Fatalf("Invalid URL policy: %v", err)
+		}
+		d := policy.Evaluate(args[0])
+		fmt.Printf("url    : %s\n", args[0])
+		fmt.Printf("action : %s\n", d.Action)
+		fmt.Printf("rule   : %s\n", d.Rule)
+		fmt.Printf("reason : %s\n", d.Reason)
+	},
+}
+
+func init() {
+	policyCmd.AddCommand(policyTestCmd)
+	rootCmd.AddCommand(policyCmd)
+}
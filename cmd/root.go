@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 
 	"github.com/mbreese/mcpfurl/fetchurl"
@@ -37,6 +38,12 @@ var debugCmd = &cobra.Command{
 				if userConfig.MCPFurlCfg.UsePandoc != nil {
 					fmt.Printf("  use_pandoc     : %t\n", *userConfig.MCPFurlCfg.UsePandoc)
 				}
+				if userConfig.MCPFurlCfg.FetchMode != nil {
+					fmt.Printf("  fetch_mode     : %s\n", *userConfig.MCPFurlCfg.FetchMode)
+				}
+				if userConfig.MCPFurlCfg.Extraction != nil {
+					fmt.Printf("  extraction_mode: %s\n", *userConfig.MCPFurlCfg.Extraction)
+				}
 				if userConfig.MCPFurlCfg.SearchEngine != nil {
 					fmt.Printf("  search_engine  : %s\n", *userConfig.MCPFurlCfg.SearchEngine)
 				}
@@ -52,6 +59,21 @@ var debugCmd = &cobra.Command{
 				if len(userConfig.MCPFurlCfg.Deny) > 0 {
 					fmt.Printf("  disallow      : %v\n", userConfig.MCPFurlCfg.Deny)
 				}
+				if userConfig.MCPFurlCfg.MaxTabs != nil {
+					fmt.Printf("  max_tabs       : %d\n", *userConfig.MCPFurlCfg.MaxTabs)
+				}
+				if userConfig.MCPFurlCfg.TabQueueWait != nil {
+					fmt.Printf("  tab_queue_timeout : %s\n", *userConfig.MCPFurlCfg.TabQueueWait)
+				}
+				if userConfig.MCPFurlCfg.RecycleTabs != nil {
+					fmt.Printf("  browser_recycle_tabs  : %d\n", *userConfig.MCPFurlCfg.RecycleTabs)
+				}
+				if userConfig.MCPFurlCfg.RecycleAfter != nil {
+					fmt.Printf("  browser_recycle_after : %s\n", *userConfig.MCPFurlCfg.RecycleAfter)
+				}
+				if userConfig.MCPFurlCfg.BrowserWSURL != nil {
+					fmt.Printf("  browser_ws_url : %s\n", *userConfig.MCPFurlCfg.BrowserWSURL)
+				}
 			}
 			if userConfig.HTTPCfg != nil {
 				fmt.Println("[http]")
@@ -65,6 +87,28 @@ var debugCmd = &cobra.Command{
 					fmt.Printf("  master_key: ********\n")
 				}
 			}
+			for _, k := range userConfig.APIKeysCfg {
+				fmt.Println("[[api_keys]]")
+				if k.Name != nil {
+					fmt.Printf("  name : %s\n", *k.Name)
+				}
+				fmt.Printf("  tools: %v\n", k.Tools)
+			}
+			if cfg := userConfig.OAuthCfg; cfg != nil {
+				fmt.Println("[oauth]")
+				if cfg.Issuer != nil {
+					fmt.Printf("  issuer  : %s\n", *cfg.Issuer)
+				}
+				if cfg.Audience != nil {
+					fmt.Printf("  audience: %s\n", *cfg.Audience)
+				}
+				if cfg.JWKS != nil {
+					fmt.Printf("  jwks    : %s\n", *cfg.JWKS)
+				}
+				for scope, tools := range cfg.Scopes {
+					fmt.Printf("  scope %s: %v\n", scope, tools)
+				}
+			}
 			if userConfig.GoogleCustomCfg != nil {
 				fmt.Println("[google_custom]")
 				if userConfig.GoogleCustomCfg.Cx != nil {
@@ -95,6 +139,11 @@ var debugCmd = &cobra.Command{
 		// fmt.Printf("web_driver_path: %s\n", webDriverPath)
 		// fmt.Printf("web_driver_log : %s\n", webDriverLog)
 		fmt.Printf("use_pandoc     : %t\n", usePandoc)
+		fmt.Printf("fetch_mode     : %s\n", fetchMode)
+		fmt.Printf("extraction_mode: %s\n", extractionMode)
+		fmt.Printf("flatten_frames : %t\n", flattenFrames)
+		fmt.Printf("fail_on_exceptions : %t\n", failOnExceptions)
+		fmt.Printf("block          : %v\n", blockList)
 		fmt.Printf("verbose        : %t\n", verbose)
 		fmt.Printf("search_engine  : %s\n", searchEngine)
 		fmt.Printf("cache_path     : %s\n", cachePath)
@@ -110,8 +159,37 @@ var debugCmd = &cobra.Command{
 		fmt.Printf("mcp_addr       : %s\n", mcpAddr)
 		fmt.Printf("mcp_port       : %d\n", mcpPort)
 		fmt.Printf("image_max_bytes: %d\n", fetchurl.DefaultMaxDownloadBytes)
+		fmt.Printf("max_tabs       : %d\n", maxTabs)
+		fmt.Printf("tab_queue_timeout : %s\n", tabQueueTimeout)
+		fmt.Printf("browser_recycle_tabs  : %d\n", browserRecycleTabs)
+		fmt.Printf("browser_recycle_after : %s\n", browserRecycleAfter)
+		fmt.Printf("browser_ws_url : %s\n", browserWSURL)
+		fmt.Printf("proxy          : %s\n", redactedURL(proxyURL))
+		fmt.Printf("proxy_bypass   : %v\n", proxyBypass)
+		fmt.Printf("robots         : %s\n", robotsMode)
+		fmt.Printf("robots_user_agent : %s\n", robotsUserAgent)
+		fmt.Printf("block_private_ips : %t\n", blockPrivateIPs)
+		fmt.Printf("allow_networks : %v\n", allowNetworks)
+		for _, r := range proxyRules {
+			fmt.Printf("proxy_rule     : %s -> %s\n", r.URL, redactedURL(r.Proxy))
+		}
+		switch {
+		case fetchMode == fetchurl.FetchModeHTTP:
+			fmt.Printf("browser_mode   : %s\n", fetchurl.BrowserModeDisabled)
+		case browserWSURL != "":
+			fmt.Printf("browser_mode   : %s\n", fetchurl.BrowserModeRemote)
+		default:
+			fmt.Printf("browser_mode   : %s\n", fetchurl.BrowserModeLocal)
+		}
+		for _, p := range profiles {
+			fmt.Printf("profile: %s\n", p.Url)
+		}
 		fmt.Printf("allow  : %v\n", httpAllowGlobs)
 		fmt.Printf("deny   : %v\n", httpDenyGlobs)
+		for _, r := range policyRules {
+			fmt.Printf("policy : %s %s\n", r.Action, r.Name)
+		}
+		fmt.Printf("policy_default : %s\n", policyDefault)
 	},
 }
 
@@ -147,3 +225,11 @@ func Execute() {
 		os.Exit(1)
 	}
 }
+
+// redactedURL hides the password in a proxy URL.
+func redactedURL(s string) string {
+	if u, err := url.Parse(s); err == nil {
+		return u.Redacted()
+	}
+	return s
+}
@@ -38,21 +38,40 @@ var mcpCmd = &cobra.Command{
 			// WebDriverPort:    webDriverPort,
 			// ChromeDriverPath: webDriverPath,
 			// WebDriverLogging:   webDriverLog,
-			Logger:           logger,
-			MaxDownloadBytes: fetchurl.DefaultMaxDownloadBytes,
-			UsePandoc:        usePandoc,
-			GoogleSearchCx:   googleCx,
-			GoogleSearchKey:  googleKey,
-			SearchEngine:     searchEngine,
-			CachePath:        cachePath,
-			CacheExpires:     cacheExpires,
-			AllowedURLGlobs:  httpAllowGlobs,
-			DenyURLGlobs:     httpDenyGlobs,
-			SummarizeBaseURL: summaryBaseURL,
-			SummarizeApiKey:  summaryAPIKey,
-			SummarizeModel:   summaryLLMModel,
-			SummarizeShort:   summaryShort,
-			UrlSelectors:     selectors,
+			Logger:              logger,
+			MaxDownloadBytes:    fetchurl.DefaultMaxDownloadBytes,
+			UsePandoc:           usePandoc,
+			FetchMode:           fetchMode,
+			ExtractionMode:      extractionMode,
+			FlattenFrames:       flattenFrames,
+			FailOnExceptions:    failOnExceptions,
+			Blocking:            parseBlockList(),
+			Proxy:               proxyConfig(),
+			RobotsMode:          robotsMode,
+			RobotsUserAgent:     robotsUserAgent,
+			BlockPrivateIPs:     blockPrivateIPs,
+			AllowedNetworks:     allowNetworks,
+			TrackerBlocklist:    trackerBlocklist,
+			GoogleSearchCx:      googleCx,
+			GoogleSearchKey:     googleKey,
+			SearchEngine:        searchEngine,
+			CachePath:           cachePath,
+			CacheExpires:        cacheExpires,
+			AllowedURLGlobs:     httpAllowGlobs,
+			DenyURLGlobs:        httpDenyGlobs,
+			PolicyRules:         policyRules,
+			PolicyDefault:       policyDefault,
+			SummarizeBaseURL:    summaryBaseURL,
+			SummarizeApiKey:     summaryAPIKey,
+			SummarizeModel:      summaryLLMModel,
+			SummarizeShort:      summaryShort,
+			UrlSelectors:        selectors,
+			Profiles:            profiles,
+			MaxTabs:             maxTabs,
+			TabQueueTimeout:     tabQueueTimeout,
+			BrowserRecycleTabs:  browserRecycleTabs,
+			BrowserRecycleAfter: browserRecycleAfter,
+			BrowserWSURL:        browserWSURL,
 		}, mcpserver.MCPServerOptions{
 			FetchDesc:      defaultFetchDesc,
 			ImageDesc:      defaultImageDesc,
@@ -94,21 +113,40 @@ var mcpHttpCmd = &cobra.Command{
 			// WebDriverPort:    webDriverPort,
 			// ChromeDriverPath: webDriverPath,
 			// WebDriverLogging:   webDriverLog,
-			Logger:           logger,
-			MaxDownloadBytes: fetchurl.DefaultMaxDownloadBytes,
-			UsePandoc:        usePandoc,
-			GoogleSearchCx:   googleCx,
-			GoogleSearchKey:  googleKey,
-			SearchEngine:     searchEngine,
-			CachePath:        cachePath,
-			CacheExpires:     cacheExpires,
-			AllowedURLGlobs:  httpAllowGlobs,
-			DenyURLGlobs:     httpDenyGlobs,
-			SummarizeBaseURL: summaryBaseURL,
-			SummarizeApiKey:  summaryAPIKey,
-			SummarizeModel:   summaryLLMModel,
-			SummarizeShort:   summaryShort,
-			UrlSelectors:     selectors,
+			Logger:              logger,
+			MaxDownloadBytes:    fetchurl.DefaultMaxDownloadBytes,
+			UsePandoc:           usePandoc,
+			FetchMode:           fetchMode,
+			ExtractionMode:      extractionMode,
+			FlattenFrames:       flattenFrames,
+			FailOnExceptions:    failOnExceptions,
+			Blocking:            parseBlockList(),
+			Proxy:               proxyConfig(),
+			RobotsMode:          robotsMode,
+			RobotsUserAgent:     robotsUserAgent,
+			BlockPrivateIPs:     blockPrivateIPs,
+			AllowedNetworks:     allowNetworks,
+			TrackerBlocklist:    trackerBlocklist,
+			GoogleSearchCx:      googleCx,
+			GoogleSearchKey:     googleKey,
+			SearchEngine:        searchEngine,
+			CachePath:           cachePath,
+			CacheExpires:        cacheExpires,
+			AllowedURLGlobs:     httpAllowGlobs,
+			DenyURLGlobs:        httpDenyGlobs,
+			PolicyRules:         policyRules,
+			PolicyDefault:       policyDefault,
+			SummarizeBaseURL:    summaryBaseURL,
+			SummarizeApiKey:     summaryAPIKey,
+			SummarizeModel:      summaryLLMModel,
+			SummarizeShort:      summaryShort,
+			UrlSelectors:        selectors,
+			Profiles:            profiles,
+			MaxTabs:             maxTabs,
+			TabQueueTimeout:     tabQueueTimeout,
+			BrowserRecycleTabs:  browserRecycleTabs,
+			BrowserRecycleAfter: browserRecycleAfter,
+			BrowserWSURL:        browserWSURL,
 		}, mcpserver.MCPServerOptions{
 			Addr:           mcpAddr,
 			Port:           mcpPort,
@@ -122,6 +160,8 @@ var mcpHttpCmd = &cobra.Command{
 			DisableImage:   disableImage,
 			DisableSummary: disableSummary,
 			EnableAPI:      enableAPI,
+			APIKeys:        apiKeys,
+			OAuth:          oauthOpts,
 			CrawlResources: crawlResources,
 		})
 	},
@@ -130,6 +170,8 @@ var mcpHttpCmd = &cobra.Command{
 var mcpPort int
 var mcpAddr string
 var masterKey string
+var apiKeys []mcpserver.APIKey
+var oauthOpts *mcpserver.OAuthOptions
 var httpAllowGlobs []string
 var httpDenyGlobs []string
 
@@ -146,6 +188,28 @@ var enableAPI bool
 var crawlResources []mcpserver.CrawlResourceConfig
 
 var selectors []fetchurl.UrlSelector
+var profiles []fetchurl.FetchProfile
+
+var fetchMode string
+var extractionMode string
+var flattenFrames bool
+var failOnExceptions bool
+var blockList []string
+var trackerBlocklist []string
+var proxyURL string
+var proxyBypass []string
+var proxyRules []fetchurl.ProxyRule
+var robotsMode string
+var robotsUserAgent string
+var blockPrivateIPs bool
+var allowNetworks []string
+var policyRules []fetchurl.PolicyRule
+var policyDefault string
+var maxTabs int
+var tabQueueTimeout time.Duration
+var browserRecycleTabs int
+var browserRecycleAfter time.Duration
+var browserWSURL string
 
 func init() {
 	mcpHttpCmd.Flags().IntVarP(&mcpPort, "port", "p", 8080, "Start the MCP server on this port")
@@ -160,6 +224,20 @@ func init() {
 	mcpHttpCmd.Flags().BoolVar(&disableSearch, "disable-search", false, "Disable the Search function")
 	mcpHttpCmd.Flags().BoolVar(&disableSummary, "disable-summary", false, "Disable the Summary function")
 	mcpHttpCmd.Flags().BoolVar(&enableAPI, "enable-api", false, "Expose REST API endpoints at /api/*")
+	mcpHttpCmd.Flags().StringVar(&extractionMode, "extraction", fetchurl.ExtractionSelector, "Content extraction: selector (matching [[selectors]] entry, else the whole body), body (always the whole body) or readability (matching selector, else the main content only)")
+	mcpHttpCmd.Flags().BoolVar(&flattenFrames, "flatten-frames", false, "Inline the content of iframes and open shadow roots when fetching with a browser")
+	mcpHttpCmd.Flags().BoolVar(&failOnExceptions, "fail-on-exceptions", false, "Fail browser fetches of pages that throw uncaught JS exceptions before they are ready")
+	mcpHttpCmd.Flags().StringSliceVar(&blockList, "block", nil, "Requests to block when fetching with a browser: resource types (image, media, font, stylesheet, script, ...), trackers and/or policy (apply allow/deny to sub-resources)")
+	mcpHttpCmd.Flags().StringVar(&fetchMode, "fetch-mode", fetchurl.FetchModeBrowser, "How to fetch pages: browser, http (no Chrome) or auto (http, falling back to browser)")
+	mcpHttpCmd.Flags().StringVar(&proxyURL, "proxy", "", "Proxy for outbound requests: http://, https://, socks5:// or socks5h://, with optional user:password@")
+	mcpHttpCmd.Flags().StringSliceVar(&proxyBypass, "proxy-bypass", nil, "Hosts that don't use the proxy: domains, host globs or IP ranges")
+	mcpHttpCmd.Flags().StringVar(&robotsMode, "robots", fetchurl.RobotsOff, "Respect robots.txt: off, crawl (pages visited by crawls) or all (every fetch)")
+	mcpHttpCmd.Flags().StringVar(&robotsUserAgent, "robots-user-agent", fetchurl.DefaultRobotsUserAgent, "User agent matched against robots.txt rules")
+	mcpHttpCmd.Flags().BoolVar(&blockPrivateIPs, "block-private-ips", false, "Refuse URLs that resolve to loopback, private, link-local or other reserved addresses")
+	mcpHttpCmd.Flags().StringSliceVar(&allowNetworks, "allow-networks", nil, "Networks (CIDRs) exempt from --block-private-ips")
+	mcpHttpCmd.Flags().StringVar(&browserWSURL, "browser-ws-url", "", "Attach to a running Chrome at this DevTools URL (ws://host:9222/devtools/browser/... or http://host:9222) instead of launching one")
+	mcpHttpCmd.Flags().IntVar(&maxTabs, "max-tabs", fetchurl.DefaultMaxTabs, "Maximum number of concurrent browser tabs (extra requests wait in a queue)")
+	mcpHttpCmd.Flags().DurationVar(&tabQueueTimeout, "tab-queue-timeout", fetchurl.DefaultTabQueueTimeout, "Maximum time a request waits in the queue for a free browser tab")
 	mcpHttpCmd.Flags().StringVar(&googleCx, "google-cx", "", "cx value for Google Custom Search")
 	mcpHttpCmd.Flags().StringVar(&googleKey, "google-key", "", "API key for Google Custom Search")
 	mcpHttpCmd.Flags().StringVar(&searchEngine, "search-engine", "google_custom", "Search engine to use (e.g. google_custom)")
@@ -183,6 +261,20 @@ func init() {
 	mcpCmd.Flags().BoolVar(&disableImage, "disable-image", false, "Disable the Image function")
 	mcpCmd.Flags().BoolVar(&disableSearch, "disable-search", false, "Disable the Search function")
 	mcpCmd.Flags().BoolVar(&disableSummary, "disable-summary", false, "Disable the Summary function")
+	mcpCmd.Flags().StringVar(&extractionMode, "extraction", fetchurl.ExtractionSelector, "Content extraction: selector (matching [[selectors]] entry, else the whole body), body (always the whole body) or readability (matching selector, else the main content only)")
+	mcpCmd.Flags().BoolVar(&flattenFrames, "flatten-frames", false, "Inline the content of iframes and open shadow roots when fetching with a browser")
+	mcpCmd.Flags().BoolVar(&failOnExceptions, "fail-on-exceptions", false, "Fail browser fetches of pages that throw uncaught JS exceptions before they are ready")
+	mcpCmd.Flags().StringSliceVar(&blockList, "block", nil, "Requests to block when fetching with a browser: resource types (image, media, font, stylesheet, script, ...), trackers and/or policy (apply allow/deny to sub-resources)")
+	mcpCmd.Flags().StringVar(&fetchMode, "fetch-mode", fetchurl.FetchModeBrowser, "How to fetch pages: browser, http (no Chrome) or auto (http, falling back to browser)")
+	mcpCmd.Flags().StringVar(&proxyURL, "proxy", "", "Proxy for outbound requests: http://, https://, socks5:// or socks5h://, with optional user:password@")
+	mcpCmd.Flags().StringSliceVar(&proxyBypass, "proxy-bypass", nil, "Hosts that don't use the proxy: domains, host globs or IP ranges")
+	mcpCmd.Flags().StringVar(&robotsMode, "robots", fetchurl.RobotsOff, "Respect robots.txt: off, crawl (pages visited by crawls) or all (every fetch)")
+	mcpCmd.Flags().StringVar(&robotsUserAgent, "robots-user-agent", fetchurl.DefaultRobotsUserAgent, "User agent matched against robots.txt rules")
+	mcpCmd.Flags().BoolVar(&blockPrivateIPs, "block-private-ips", false, "Refuse URLs that resolve to loopback, private, link-local or other reserved addresses")
+	mcpCmd.Flags().StringSliceVar(&allowNetworks, "allow-networks", nil, "Networks (CIDRs) exempt from --block-private-ips")
+	mcpCmd.Flags().StringVar(&browserWSURL, "browser-ws-url", "", "Attach to a running Chrome at this DevTools URL (ws://host:9222/devtools/browser/... or http://host:9222) instead of launching one")
+	mcpCmd.Flags().IntVar(&maxTabs, "max-tabs", fetchurl.DefaultMaxTabs, "Maximum number of concurrent browser tabs (extra requests wait in a queue)")
+	mcpCmd.Flags().DurationVar(&tabQueueTimeout, "tab-queue-timeout", fetchurl.DefaultTabQueueTimeout, "Maximum time a request waits in the queue for a free browser tab")
 	mcpCmd.Flags().StringVar(&googleCx, "google-cx", "", "cx value for Google Custom Search")
 	mcpCmd.Flags().StringVar(&googleKey, "google-key", "", "API key for Google Custom Search")
 	mcpCmd.Flags().StringVar(&searchEngine, "search-engine", "google_custom", "Search engine to use (e.g. google_custom)")
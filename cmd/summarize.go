@@ -44,13 +44,28 @@ var summarizeCmd = &cobra.Command{
 			// WebDriverPort:    webDriverPort,
 			// ChromeDriverPath: webDriverPath,
 			Logger:           logger,
+			FetchMode:        fetchMode,
+			ExtractionMode:   extractionMode,
+			FlattenFrames:    flattenFrames,
+			FailOnExceptions: failOnExceptions,
+			Blocking:         parseBlockList(),
+			Proxy:            proxyConfig(),
+			RobotsMode:       robotsMode,
+			RobotsUserAgent:  robotsUserAgent,
+			BlockPrivateIPs:  blockPrivateIPs,
+			AllowedNetworks:  allowNetworks,
+			TrackerBlocklist: trackerBlocklist,
+			BrowserWSURL:     browserWSURL,
 			AllowedURLGlobs:  httpAllowGlobs,
 			DenyURLGlobs:     httpDenyGlobs,
+			PolicyRules:      policyRules,
+			PolicyDefault:    policyDefault,
 			SummarizeBaseURL: summaryBaseURL,
 			SummarizeApiKey:  summaryAPIKey,
 			SummarizeModel:   summaryLLMModel,
 			SummarizeShort:   summaryShort,
 			UrlSelectors:     selectors,
+			Profiles:         profiles,
 		})
 		if err != nil {
 			log.Fatalf("ERROR: %v\n", err)
@@ -61,7 +76,8 @@ var summarizeCmd = &cobra.Command{
 
 		defer fetcher.Stop()
 
-		ctx := context.Background()
+		// the command line is the operator's, who may fetch require_auth URLs
+		ctx := fetchurl.WithAuthenticatedCaller(context.Background())
 		summary, err := fetcher.SummarizeURL(ctx, url, selector, false)
 		if err != nil {
 			log.Fatalf("ERROR: %v\n", err)
@@ -0,0 +1,216 @@
+package fetchurl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+	"github.com/chromedp/chromedp/kb"
+)
+
+const (
+	ActionClick  = "click"  // click the element matching Selector
+	ActionType   = "type"   // type Text into the element matching Selector
+	ActionPress  = "press"  // press Key (ex: Enter, Escape, PageDown)
+	ActionScroll = "scroll" // scroll to the bottom of the page Times times
+	ActionWait   = "wait"   // wait for Selector to be visible, or for Duration
+	ActionEval   = "eval"   // evaluate the JS in Script
+
+	// DefaultActionTimeout bounds each page action. Actions are best-effort:
+	// one that times out (ex: a cookie banner that never showed up) is logged
+	// and skipped.
+	DefaultActionTimeout = 5 * time.Second
+
+	// maxScrolls caps ActionScroll.Times
+	maxScrolls = 50
+)
+
+// PageAction is one scripted interaction to run after the page is ready and
+// before its HTML is captured, such as expanding "Show more" sections or
+// dismissing a cookie banner.
+type PageAction struct {
+	Action   string `json:"action" jsonschema:"One of: click, type, press, scroll, wait, eval"`
+	Selector string `json:"selector,omitempty" jsonschema:"CSS selector (click, type, wait)"`
+	Text     string `json:"text,omitempty" jsonschema:"Text to type (type)"`
+	Key      string `json:"key,omitempty" jsonschema:"Key to press, ex: Enter, Escape, Tab, PageDown (press)"`
+	Times    int    `json:"times,omitempty" jsonschema:"Number of times to scroll to the bottom, default 1 (scroll)"`
+	Duration string `json:"duration,omitempty" jsonschema:"Time to wait, ex: 500ms or 2s, when no selector is given (wait)"`
+	Script   string `json:"script,omitempty" jsonschema:"JavaScript to evaluate (eval)"`
+}
+
+// ParsePageActions parses a JSON array of actions, as accepted by the REST
+// API and the fetch command, and validates them.
+func ParsePageActions(spec string) ([]PageAction, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+	var actions []PageAction
+	if err := json.Unmarshal([]byte(spec), &actions); err != nil {
+		return nil, fmt.Errorf("invalid actions: %w", err)
+	}
+	if err := ValidatePageActions(actions); err != nil {
+		return nil, err
+	}
+	return actions, nil
+}
+
+// ValidatePageActions checks that every action is known and has the fields
+// it needs.
+func ValidatePageActions(actions []PageAction) error {
+	for i, a := range actions {
+		if err := a.validate(); err != nil {
+			return fmt.Errorf("action %d (%s): %w", i+1, a.Action, err)
+		}
+	}
+	return nil
+}
+
+func (a PageAction) validate() error {
+	switch a.Action {
+	case ActionClick:
+		if a.Selector == "" {
+			return fmt.Errorf("missing selector")
+		}
+	case ActionType:
+		if a.Selector == "" {
+			return fmt.Errorf("missing selector")
+		}
+	case ActionPress:
+		if a.Key == "" {
+			return fmt.Errorf("missing key")
+		}
+	case ActionScroll:
+		if a.Times < 0 || a.Times > maxScrolls {
+			return fmt.Errorf("times must be between 0 and %d", maxScrolls)
+		}
+	case ActionWait:
+		if a.Selector == "" && a.Duration == "" {
+			return fmt.Errorf("missing selector or duration")
+		}
+		if a.Duration != "" {
+			if _, err := time.ParseDuration(a.Duration); err != nil {
+				return fmt.Errorf("invalid duration: %w", err)
+			}
+		}
+	case ActionEval:
+		if a.Script == "" {
+			return fmt.Errorf("missing script")
+		}
+	default:
+		return fmt.Errorf("unknown action (expected click, type, press, scroll, wait or eval)")
+	}
+	return nil
+}
+
+func (a PageAction) String() string {
+	switch a.Action {
+	case ActionClick, ActionType:
+		return fmt.Sprintf("%s:%s", a.Action, a.Selector)
+	case ActionPress:
+		return fmt.Sprintf("%s:%s", a.Action, a.Key)
+	case ActionWait:
+		if a.Selector != "" {
+			return fmt.Sprintf("%s:%s", a.Action, a.Selector)
+		}
+		return fmt.Sprintf("%s:%s", a.Action, a.Duration)
+	}
+	return a.Action
+}
+
+// runPageActions runs actions in order. Each action gets DefaultActionTimeout
+// (longer for scrolls and waits with a duration); failures are logged and
+// the next action runs.
+func (w *WebFetcher) runPageActions(actions []PageAction) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		for _, a := range actions {
+			timeout := DefaultActionTimeout
+			if a.Action == ActionScroll {
+				timeout = time.Duration(max(a.Times, 1)) * DefaultActionTimeout
+			} else if d, err := time.ParseDuration(a.Duration); err == nil && a.Selector == "" {
+				timeout = d + time.Second
+			}
+
+			actCtx, cancel := context.WithTimeout(ctx, timeout)
+			err := a.do(actCtx)
+			cancel()
+
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				if errors.Is(err, context.DeadlineExceeded) {
+					w.opts.Logger.Warn("page action timed out, skipping", "action", a.String(), "timeout", timeout)
+				} else {
+					w.opts.Logger.Warn("page action failed, skipping", "action", a.String(), "error", err)
+				}
+				continue
+			}
+			w.opts.Logger.Debug("ran page action", "action", a.String())
+		}
+		return nil
+	})
+}
+
+func (a PageAction) do(ctx context.Context) error {
+	switch a.Action {
+	case ActionClick:
+		return chromedp.Run(ctx,
+			chromedp.WaitVisible(a.Selector, chromedp.ByQuery),
+			chromedp.Click(a.Selector, chromedp.ByQuery),
+			// give any handlers a moment to update the page
+			chromedp.Sleep(250*time.Millisecond),
+		)
+	case ActionType:
+		return chromedp.Run(ctx,
+			chromedp.WaitVisible(a.Selector, chromedp.ByQuery),
+			chromedp.SendKeys(a.Selector, a.Text, chromedp.ByQuery),
+		)
+	case ActionPress:
+		return chromedp.KeyEvent(keyNamed(a.Key)).Do(ctx)
+	case ActionScroll:
+		for i := 0; i < max(a.Times, 1); i++ {
+			if err := chromedp.Run(ctx,
+				chromedp.Evaluate(`window.scrollTo(0, document.body ? document.body.scrollHeight : 0)`, nil),
+				// wait for lazy loaded content
+				chromedp.Sleep(500*time.Millisecond),
+			); err != nil {
+				return err
+			}
+		}
+		return nil
+	case ActionWait:
+		if a.Selector != "" {
+			return chromedp.WaitVisible(a.Selector, chromedp.ByQuery).Do(ctx)
+		}
+		d, err := time.ParseDuration(a.Duration)
+		if err != nil {
+			return err
+		}
+		return chromedp.Sleep(d).Do(ctx)
+	case ActionEval:
+		// the result is ignored; promises are awaited
+		return chromedp.Evaluate(a.Script, nil, func(p *runtime.EvaluateParams) *runtime.EvaluateParams {
+			return p.WithAwaitPromise(true)
+		}).Do(ctx)
+	}
+	return fmt.Errorf("unknown action %q", a.Action)
+}
+
+// keyNamed maps a DOM key name (Enter, Escape, PageDown, ...) to the
+// character chromedp uses for it. Anything else is sent as literal text.
+func keyNamed(name string) string {
+	if len([]rune(name)) > 1 {
+		for r, k := range kb.Keys {
+			if strings.EqualFold(k.Key, name) || strings.EqualFold(k.Code, name) {
+				return string(r)
+			}
+		}
+	}
+	return name
+}
@@ -0,0 +1,529 @@
+package fetchurl
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+const (
+	// BlockTrackers blocks requests matching the tracker blocklist.
+	BlockTrackers = "trackers"
+	// BlockPolicy applies the URL policy to sub-resource requests.
+	BlockPolicy = "policy"
+	// BlockNone blocks nothing, overriding the configured list.
+	BlockNone = "none"
+
+	// blockedPrivateNetwork counts the requests the ipGuard rejected.
+	blockedPrivateNetwork = "private_network"
+)
+
+//go:embed trackers.txt
+var bundledTrackers string
+
+// blockableTypes are the resource types that can be blocked, by their name
+// in a block list. Documents and fetch/XHR requests can't be, since pages
+// need them to render.
+var blockableTypes = map[string]network.ResourceType{
+	"image":      network.ResourceTypeImage,
+	"media":      network.ResourceTypeMedia,
+	"font":       network.ResourceTypeFont,
+	"stylesheet": network.ResourceTypeStylesheet,
+	"script":     network.ResourceTypeScript,
+	"texttrack":  network.ResourceTypeTextTrack,
+	"manifest":   network.ResourceTypeManifest,
+	"ping":       network.ResourceTypePing,
+	"other":      network.ResourceTypeOther,
+}
+
+// ResourceBlocking selects the requests to block while a page is fetched
+// with the browser. The zero value blocks nothing.
+type ResourceBlocking struct {
+	Types     []network.ResourceType // resource types to block (ex: image, media, font)
+	Trackers  bool                   // block URLs on the tracker blocklist
+	URLPolicy bool                   // block sub-resources the URL policy rejects
+}
+
+// IsZero reports whether nothing is blocked.
+func (b ResourceBlocking) IsZero() bool {
+	return len(b.Types) == 0 && !b.Trackers && !b.URLPolicy
+}
+
+// ParseResourceBlocking parses a block list: resource types (image, media,
+// font, stylesheet, script, texttrack, manifest, ping, other),
+// "trackers" and "policy". Items may also be comma separated. "none" blocks
+// nothing.
+func ParseResourceBlocking(list []string) (ResourceBlocking, error) {
+	var items []string
+	for _, item := range list {
+		for _, v := range strings.Split(item, ",") {
+			if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+				items = append(items, v)
+			}
+		}
+	}
+
+	var b ResourceBlocking
+	for _, item := range items {
+		switch item {
+		case BlockNone:
+			if len(items) > 1 {
+				return ResourceBlocking{}, fmt.Errorf("%q can't be combined with other block values", BlockNone)
+			}
+		case BlockTrackers:
+			b.Trackers = true
+		case BlockPolicy:
+			b.URLPolicy = true
+		default:
+			t, ok := blockableTypes[item]
+			if !ok {
+				return ResourceBlocking{}, fmt.Errorf("unknown block value %q (expected a resource type, %s, %s or %s)", item, BlockTrackers, BlockPolicy, BlockNone)
+			}
+			b.Types = append(b.Types, t)
+		}
+	}
+	return b, nil
+}
+
+// LoadTrackerBlocklist reads a blocklist file in the format of the bundled
+// trackers.txt.
+func LoadTrackerBlocklist(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading tracker blocklist: %w", err)
+	}
+	defer f.Close()
+	patterns := parseBlocklist(bufio.NewScanner(f))
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("tracker blocklist %s is empty", path)
+	}
+	return patterns, nil
+}
+
+func parseBlocklist(scanner *bufio.Scanner) []string {
+	var patterns []string
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns
+}
+
+// trackerList matches URLs against blocklist patterns: plain domains match
+// the host and its subdomains, and patterns with * or / are URL globs.
+type trackerList struct {
+	domains map[string]bool
+	globs   []*regexp.Regexp
+}
+
+func newTrackerList(patterns []string) (*trackerList, error) {
+	if patterns == nil {
+		patterns = parseBlocklist(bufio.NewScanner(strings.NewReader(bundledTrackers)))
+	}
+	l := &trackerList{domains: map[string]bool{}}
+	for _, p := range patterns {
+		if !strings.ContainsAny(p, "*/") {
+			l.domains[strings.ToLower(p)] = true
+			continue
+		}
+		// globs without a scheme match any scheme and subdomain
+		if !strings.Contains(p, "://") {
+			p = "*" + p
+		}
+		re, err := globToRegex(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid blocklist pattern %q: %w", p, err)
+		}
+		l.globs = append(l.globs, re)
+	}
+	return l, nil
+}
+
+func (l *trackerList) match(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	for host != "" {
+		if l.domains[host] {
+			return true
+		}
+		_, parent, ok := strings.Cut(host, ".")
+		if !ok {
+			break
+		}
+		host = parent
+	}
+	for _, re := range l.globs {
+		if re.MatchString(u.String()) {
+			return true
+		}
+	}
+	return false
+}
+
+// blockingFor returns the blocking rules for a page fetch: the per-call
+// rules if there are any, else those of the matching profile, else the
+// configured ones.
+func (w *WebFetcher) blockingFor(profile *FetchProfile, override *ResourceBlocking) ResourceBlocking {
+	switch {
+	case override != nil:
+		return *override
+	case profile != nil && profile.Blocking != nil:
+		return *profile.Blocking
+	}
+	return w.opts.Blocking
+}
+
+// requestBlocker intercepts a tab's requests with the CDP Fetch domain and
+// fails the ones the rules block. The main frame's document is never
+// blocked by the rules, but each of its navigations and redirects is checked
+// against the URL policy, and so are followed requests (see follow).
+// Every request, redirects included, is checked by the ipGuard, and so is
+// the address each response came from, which catches names re-resolved by
+// Chrome to a blocked address (DNS rebinding). It also answers the login
+// challenges of proxies configured with credentials, since Chrome can't take
+// them on the command line, and adds the profile's headers to the requests
+// the profile matches.
+type requestBlocker struct {
+	rules    ResourceBlocking
+	types    map[network.ResourceType]bool
+	trackers *trackerList
+	policy   func(string) error // the URL policy, nil if it allows everything
+	proxy    *proxyRouter
+	guard    *ipGuard
+	profile  *FetchProfile // nil if it has no headers
+	logger   *slog.Logger
+
+	mu        sync.Mutex
+	blocked   map[string]int // by reason
+	authTries map[fetch.RequestID]int
+	followed  map[string]bool
+	hops      map[network.RequestID]string // last URL of each checked request, for redirects
+	denied    error                        // the first policy or BlockedAddressError
+}
+
+// newRequestBlocker returns the blocker for a tab, or nil if it has nothing
+// to do. Tabs that don't block resources still need one for the URL policy,
+// the proxy credentials, the ipGuard and the profile's headers. ctx is the
+// fetch's, for the policy's require_auth rules.
+func (w *WebFetcher) newRequestBlocker(ctx context.Context, rules ResourceBlocking, profile *FetchProfile) *requestBlocker {
+	hasPolicy := !w.policy.empty || callerPolicy(ctx) != nil
+	if profile != nil && len(profile.Headers) == 0 {
+		profile = nil
+	}
+	if rules.IsZero() && !hasPolicy && !w.proxy.hasCredentials() && w.guard == nil && profile == nil {
+		return nil
+	}
+	b := &requestBlocker{
+		rules:     rules,
+		types:     map[network.ResourceType]bool{},
+		trackers:  w.trackers,
+		proxy:     w.proxy,
+		guard:     w.guard,
+		profile:   profile,
+		logger:    w.opts.Logger,
+		blocked:   map[string]int{},
+		authTries: map[fetch.RequestID]int{},
+		followed:  map[string]bool{},
+		hops:      map[network.RequestID]string{},
+	}
+	if hasPolicy {
+		b.policy = func(rawURL string) error {
+			return w.checkPolicy(ctx, rawURL)
+		}
+	}
+	for _, t := range rules.Types {
+		b.types[t] = true
+	}
+	return b
+}
+
+// setup enables request interception. It must run before navigating. A nil
+// blocker is a no-op.
+func (b *requestBlocker) setup() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if b == nil {
+			return nil
+		}
+		tree, err := page.GetFrameTree().Do(ctx)
+		if err != nil {
+			return fmt.Errorf("getting frame tree: %w", err)
+		}
+		mainFrame := tree.Frame.ID
+
+		c := chromedp.FromContext(ctx)
+		execCtx := cdp.WithExecutor(ctx, c.Target)
+		chromedp.ListenTarget(ctx, func(ev any) {
+			switch e := ev.(type) {
+			case *fetch.EventAuthRequired:
+				go b.answerAuth(execCtx, e)
+				return
+			case *network.EventResponseReceived:
+				b.checkResponse(e)
+				return
+			}
+			e, ok := ev.(*fetch.EventRequestPaused)
+			if !ok {
+				return
+			}
+			// the handler mustn't block the event loop
+			go func() {
+				reason := ""
+				isPage := e.ResourceType == network.ResourceTypeDocument && e.FrameID == mainFrame
+				if err := b.guard.checkURL(execCtx, e.Request.URL); err != nil {
+					// other lookup errors are left to Chrome
+					var addrErr *BlockedAddressError
+					if errors.As(err, &addrErr) {
+						reason = blockedPrivateNetwork
+						if isPage {
+							b.deny(e.Request.URL, addrErr)
+						}
+					}
+				}
+				if reason == "" && b.checked(e, isPage) {
+					if err := b.checkHop(e); err != nil {
+						reason = BlockPolicy
+						b.deny(e.Request.URL, err)
+					}
+				}
+				if reason == "" && !isPage {
+					reason = b.reason(e.Request.URL, e.ResourceType)
+				}
+				var err error
+				if reason != "" {
+					b.mu.Lock()
+					b.blocked[reason]++
+					b.mu.Unlock()
+					err = fetch.FailRequest(e.RequestID, network.ErrorReasonBlockedByClient).Do(execCtx)
+				} else {
+					err = b.continueRequest(e).Do(execCtx)
+				}
+				if err != nil && ctx.Err() == nil {
+					b.logger.Debug("unable to resume intercepted request", "url", e.Request.URL, "error", err)
+				}
+			}()
+		})
+		if err := fetch.Enable().WithHandleAuthRequests(b.proxy.hasCredentials()).Do(ctx); err != nil {
+			return err
+		}
+		if b.guard != nil || b.policy != nil {
+			// Chrome doesn't pause WebSocket handshakes, so they can't be
+			// checked like other requests: pages may not open any
+			if err := network.SetBlockedURLs([]string{"ws://*", "wss://*"}).Do(ctx); err != nil {
+				return fmt.Errorf("blocking WebSockets: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// continueRequest resumes a request, with the profile's headers if the
+// profile matches its URL. Other sites, such as the page's CDNs and
+// analytics, never see them.
+func (b *requestBlocker) continueRequest(e *fetch.EventRequestPaused) *fetch.ContinueRequestParams {
+	resume := fetch.ContinueRequest(e.RequestID)
+	if b.profile == nil || !b.profile.matches(e.Request.URL) {
+		return resume
+	}
+	var headers []*fetch.HeaderEntry
+	for k, v := range e.Request.Headers {
+		if !b.profile.hasHeader(k) {
+			headers = append(headers, &fetch.HeaderEntry{Name: k, Value: fmt.Sprint(v)})
+		}
+	}
+	for k, v := range b.profile.Headers {
+		headers = append(headers, &fetch.HeaderEntry{Name: k, Value: v})
+	}
+	return resume.WithHeaders(headers)
+}
+
+// checkResponse records a response served from a blocked address. Through a
+// proxy, the address is the proxy's, and requests are only checked by URL.
+func (b *requestBlocker) checkResponse(e *network.EventResponseReceived) {
+	if b.guard == nil || b.proxy != nil || e.Response == nil {
+		return
+	}
+	ip := net.ParseIP(strings.Trim(e.Response.RemoteIPAddress, "[]"))
+	if ip == nil || !b.guard.blocked(ip) {
+		return
+	}
+	host := e.Response.URL
+	if u, err := url.Parse(e.Response.URL); err == nil {
+		host = u.Hostname()
+	}
+	b.mu.Lock()
+	b.blocked[blockedPrivateNetwork]++
+	b.mu.Unlock()
+	b.deny(e.Response.URL, &BlockedAddressError{Host: host, IP: ip})
+}
+
+// follow applies the URL policy to requests for rawURL and each of their
+// redirects, like the page's own navigations (ex: the XHR of a browser
+// download). A nil blocker is a no-op.
+func (b *requestBlocker) follow(rawURL string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.followed[followKey(rawURL)] = true
+}
+
+// followKey normalizes a URL the way Chrome reports it.
+func followKey(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	u.Host = strings.ToLower(u.Host)
+	u.Fragment = ""
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	return u.String()
+}
+
+// checked reports whether a request is checked against the URL policy:
+// the page's navigations, followed requests and their redirects.
+func (b *requestBlocker) checked(e *fetch.EventRequestPaused, isPage bool) bool {
+	if b.policy == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, redirected := b.hops[e.NetworkID]
+	return isPage || redirected || b.followed[followKey(e.Request.URL)]
+}
+
+// checkHop applies the URL policy to a checked request. A redirect
+// that isn't allowed is a RedirectDeniedError naming both hops.
+func (b *requestBlocker) checkHop(e *fetch.EventRequestPaused) error {
+	b.mu.Lock()
+	from := b.hops[e.NetworkID]
+	b.hops[e.NetworkID] = e.Request.URL
+	b.mu.Unlock()
+
+	err := b.policy(e.Request.URL)
+	if err != nil && from != "" {
+		return &RedirectDeniedError{From: from, To: e.Request.URL, Err: err}
+	}
+	return err
+}
+
+// deny records that the page, a redirect or a followed request was refused by
+// the URL policy or went to a blocked address, or that any response came
+// from one. The first one is the tab's error (see err).
+func (b *requestBlocker) deny(rawURL string, err error) {
+	b.logger.Warn("blocked browser request", "url", rawURL, "error", err)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.denied == nil {
+		b.denied = err
+	}
+}
+
+// err returns the error of the first request that was refused because of
+// the URL policy or the ipGuard (see deny). The page, or anything captured
+// from it, must then be discarded. A nil blocker returns nil.
+func (b *requestBlocker) err() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.denied
+}
+
+// answerAuth logs in to a proxy with its configured credentials. Other
+// challenges (and a proxy that rejected the credentials once already) get
+// Chrome's default response, which fails the request.
+func (b *requestBlocker) answerAuth(ctx context.Context, e *fetch.EventAuthRequired) {
+	resp := &fetch.AuthChallengeResponse{Response: fetch.AuthChallengeResponseResponseDefault}
+	if e.AuthChallenge != nil && e.AuthChallenge.Source == fetch.AuthChallengeSourceProxy {
+		b.mu.Lock()
+		b.authTries[e.RequestID]++
+		tries := b.authTries[e.RequestID]
+		b.mu.Unlock()
+
+		origin, err := url.Parse(e.AuthChallenge.Origin)
+		if err == nil && origin.Host == "" {
+			// a bare host:port
+			origin, err = url.Parse("http://" + e.AuthChallenge.Origin)
+		}
+		if err == nil {
+			if user, password, ok := b.proxy.credentials(proxyHostPort(origin)); ok && tries == 1 {
+				resp = &fetch.AuthChallengeResponse{
+					Response: fetch.AuthChallengeResponseResponseProvideCredentials,
+					Username: user,
+					Password: password,
+				}
+			} else if ok {
+				b.logger.Warn("proxy rejected the configured credentials", "proxy", e.AuthChallenge.Origin)
+				resp = &fetch.AuthChallengeResponse{Response: fetch.AuthChallengeResponseResponseCancelAuth}
+			}
+		}
+	}
+	if err := fetch.ContinueWithAuth(e.RequestID, resp).Do(ctx); err != nil && ctx.Err() == nil {
+		b.logger.Debug("unable to answer auth challenge", "url", e.Request.URL, "error", err)
+	}
+}
+
+// reason returns why a request is blocked, or "" if it isn't.
+func (b *requestBlocker) reason(rawURL string, resourceType network.ResourceType) string {
+	if b.types[resourceType] {
+		return strings.ToLower(resourceType.String())
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		// data:, blob: and the like never leave the browser
+		return ""
+	}
+	if b.rules.Trackers && b.trackers != nil && b.trackers.match(u) {
+		return BlockTrackers
+	}
+	if b.rules.URLPolicy && b.policy != nil && b.policy(rawURL) != nil {
+		return BlockPolicy
+	}
+	return ""
+}
+
+// logBlocked logs how many requests were blocked, and why.
+func (b *requestBlocker) logBlocked(targetURL string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	total := 0
+	reasons := make([]string, 0, len(b.blocked))
+	for reason, n := range b.blocked {
+		total += n
+		reasons = append(reasons, reason)
+	}
+	if total == 0 {
+		return
+	}
+	sort.Strings(reasons)
+	args := []any{"url", targetURL, "total", total}
+	for _, reason := range reasons {
+		args = append(args, reason, b.blocked[reason])
+	}
+	b.logger.Info("blocked browser requests", args...)
+}
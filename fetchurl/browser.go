@@ -0,0 +1,361 @@
+package fetchurl
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// browserInstance is one running headless Chrome. allocCtx is the chromedp
+// exec (or remote) allocator; cancelling it tears down everything (browser +
+// tabs). For a remote browser, only our connection and tabs go away.
+// browserCtx is a long-lived child of allocCtx that owns the browser process;
+// per-request tab contexts are derived from it so we don't relaunch Chrome
+// for each call.
+type browserInstance struct {
+	allocCtx   context.Context
+	allocCan   context.CancelFunc
+	browserCtx context.Context
+	browserCan context.CancelFunc
+	started    time.Time
+	tabsOpened int
+	active     int
+	retired    bool
+}
+
+// dead reports whether the browser process has gone away. chromedp cancels
+// the browser context when it loses the connection to Chrome (crash, OOM
+// kill, remote browser restarted, etc.).
+func (b *browserInstance) dead() bool {
+	return b.browserCtx.Err() != nil
+}
+
+func (b *browserInstance) close() {
+	b.browserCan()
+	b.allocCan()
+}
+
+// browserSupervisor owns the shared headless browser. It relaunches Chrome
+// when the running instance dies, and proactively recycles it after a number
+// of tabs or amount of time to keep Chrome's memory use in check. A retired
+// instance is only closed once its last open tab is done.
+//
+// If wsURL is set, the supervisor attaches to an already running Chrome over
+// its DevTools endpoint instead of launching one, and reconnects when the
+// connection drops.
+//
+// Launching and connecting happen outside mu, one at a time (connecting holds
+// the connecting semaphore), so tabs can be released and the status read
+// while Chrome is starting or unreachable.
+type browserSupervisor struct {
+	mu           sync.Mutex
+	connecting   chan struct{} // a semaphore: one launch or connection at a time
+	current      *browserInstance
+	wsURL        string
+	recycleTabs  int
+	recycleAfter time.Duration
+	extraFlags   []chromedp.ExecAllocatorOption // added to a locally launched Chrome (ex: the proxy)
+	logger       *slog.Logger
+	stopped      bool
+}
+
+func newBrowserSupervisor(wsURL string, recycleTabs int, recycleAfter time.Duration, extraFlags []chromedp.ExecAllocatorOption, logger *slog.Logger) *browserSupervisor {
+	return &browserSupervisor{
+		connecting:   make(chan struct{}, 1),
+		wsURL:        wsURL,
+		recycleTabs:  recycleTabs,
+		recycleAfter: recycleAfter,
+		extraFlags:   extraFlags,
+		logger:       logger,
+	}
+}
+
+// start launches the initial browser so startup problems are reported right
+// away instead of on the first request. A remote browser that isn't reachable
+// yet (ex: a sidecar that is still starting) is only logged; the connection
+// is retried on the first request.
+func (s *browserSupervisor) start() error {
+	if _, err := s.relaunch(context.Background()); err != nil {
+		if s.remote() {
+			s.logger.Warn("unable to connect to remote browser, will retry on first request", "url", s.wsURL, "error", err)
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *browserSupervisor) remote() bool {
+	return s.wsURL != ""
+}
+
+// relaunch makes a new browser current, unless another caller did while
+// this one waited for its turn, and returns it. ctx only bounds the wait and
+// the launch: the browser outlives it.
+func (s *browserSupervisor) relaunch(ctx context.Context) (*browserInstance, error) {
+	select {
+	case s.connecting <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-s.connecting }()
+
+	s.mu.Lock()
+	cur, stopped := s.current, s.stopped
+	s.mu.Unlock()
+	if stopped {
+		return nil, fmt.Errorf("service already stopped")
+	}
+	if cur != nil {
+		return cur, nil
+	}
+
+	inst, err := s.launch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		inst.retired = true
+		go inst.close()
+		return nil, fmt.Errorf("service already stopped")
+	}
+	s.current = inst
+	return inst, nil
+}
+
+func (s *browserSupervisor) launch(ctx context.Context) (*browserInstance, error) {
+	if !s.remote() {
+		allocCtx, allocCan := chromedp.NewExecAllocator(context.Background(), append(execAllocatorOptions(), s.extraFlags...)...)
+		return s.connect(ctx, allocCtx, allocCan, "Started headless browser")
+	}
+
+	var err error
+	for attempt := 1; attempt <= remoteConnectAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-time.After(time.Duration(attempt-1) * time.Second):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		allocCtx, allocCan := chromedp.NewRemoteAllocator(context.Background(), s.wsURL)
+		var inst *browserInstance
+		if inst, err = s.connect(ctx, allocCtx, allocCan, "Connected to remote browser"); err == nil {
+			return inst, nil
+		}
+		s.logger.Warn("remote browser connection failed", "url", s.wsURL, "attempt", attempt, "error", err)
+	}
+	return nil, err
+}
+
+// connect launches (or attaches to) the browser, giving up after
+// browserConnectTimeout or when ctx is done.
+func (s *browserSupervisor) connect(ctx context.Context, allocCtx context.Context, allocCan context.CancelFunc, msg string) (*browserInstance, error) {
+	// Launch (or attach to) the browser up front; tabs are derived from this context.
+	browserCtx, browserCan := chromedp.NewContext(allocCtx)
+	done := make(chan error, 1)
+	go func() { done <- chromedp.Run(browserCtx) }()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-time.After(browserConnectTimeout):
+		err = fmt.Errorf("timed out after %s", browserConnectTimeout)
+	}
+	if err != nil {
+		browserCan()
+		allocCan()
+		if s.remote() {
+			return nil, fmt.Errorf("connecting to remote browser at %s: %w", s.wsURL, err)
+		}
+		return nil, fmt.Errorf("starting headless browser: %w", err)
+	}
+	s.logger.Info(msg)
+
+	return &browserInstance{
+		allocCtx:   allocCtx,
+		allocCan:   allocCan,
+		browserCtx: browserCtx,
+		browserCan: browserCan,
+		started:    time.Now(),
+	}, nil
+}
+
+// acquire returns the browser to open a new tab on, relaunching it first if
+// it died or is due to be recycled. Every successful acquire must be paired
+// with a release.
+func (s *browserSupervisor) acquire(ctx context.Context) (*browserInstance, error) {
+	var launched *browserInstance
+	for {
+		s.mu.Lock()
+		if s.stopped {
+			s.mu.Unlock()
+			return nil, fmt.Errorf("service already stopped")
+		}
+		if cur := s.current; cur != nil && (cur == launched || !s.retireIfDue(cur)) {
+			cur.tabsOpened++
+			cur.active++
+			s.mu.Unlock()
+			return cur, nil
+		}
+		s.mu.Unlock()
+
+		var err error
+		if launched, err = s.relaunch(ctx); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retireIfDue retires cur if it died or is due to be recycled, and reports
+// whether it did. Must hold s.mu.
+func (s *browserSupervisor) retireIfDue(cur *browserInstance) bool {
+	switch {
+	case cur.dead() && s.remote():
+		s.logger.Warn("lost connection to remote browser, reconnecting", "url", s.wsURL)
+		s.retire(cur)
+	case cur.dead():
+		s.logger.Warn("headless browser is no longer running, relaunching")
+		s.retire(cur)
+	case s.recycleTabs > 0 && cur.tabsOpened >= s.recycleTabs:
+		s.logger.Info("recycling headless browser", "tabs_opened", cur.tabsOpened)
+		s.retire(cur)
+	case s.recycleAfter > 0 && time.Since(cur.started) >= s.recycleAfter:
+		s.logger.Info("recycling headless browser", "uptime", time.Since(cur.started).Round(time.Second))
+		s.retire(cur)
+	default:
+		return false
+	}
+	return true
+}
+
+func (s *browserSupervisor) release(inst *browserInstance) {
+	s.mu.Lock()
+	inst.active--
+	closeNow := inst.retired && inst.active == 0
+	s.mu.Unlock()
+
+	if closeNow {
+		inst.close()
+	}
+}
+
+// retire takes inst out of rotation. It is closed right away if no tabs are
+// using it, otherwise by the release of its last tab. Must hold s.mu.
+func (s *browserSupervisor) retire(inst *browserInstance) {
+	if inst.retired {
+		return
+	}
+	inst.retired = true
+	if s.current == inst {
+		s.current = nil
+	}
+	if inst.active == 0 {
+		go inst.close()
+	}
+}
+
+// status reports the browser mode and whether it is currently connected.
+func (s *browserSupervisor) status() BrowserStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := BrowserStatus{Mode: BrowserModeLocal}
+	if s.remote() {
+		st.Mode = BrowserModeRemote
+		st.Endpoint = s.wsURL
+	}
+	if cur := s.current; cur != nil && !cur.dead() {
+		st.Connected = true
+		st.Uptime = time.Since(cur.started).Round(time.Second).String()
+		st.TabsOpened = cur.tabsOpened
+		st.ActiveTabs = cur.active
+	}
+	return st
+}
+
+func (s *browserSupervisor) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopped = true
+	if s.current != nil {
+		cur := s.current
+		s.current = nil
+		cur.retired = true
+		cur.close()
+	}
+}
+
+const (
+	BrowserModeLocal    = "local"    // Chrome launched by mcpfurl
+	BrowserModeRemote   = "remote"   // attached to an existing Chrome over CDP
+	BrowserModeDisabled = "disabled" // no browser (FetchModeHTTP)
+
+	// remoteConnectAttempts is how many times to try (re)connecting to a
+	// remote browser before failing the request.
+	remoteConnectAttempts = 3
+
+	// browserConnectTimeout bounds each launch of, or connection to, the
+	// browser.
+	browserConnectTimeout = 30 * time.Second
+)
+
+// BrowserStatus describes the browser backing a WebFetcher, for health checks.
+type BrowserStatus struct {
+	Mode       string `json:"mode"`
+	Endpoint   string `json:"-"` // not in /health: it gives full control of the browser
+	Connected  bool   `json:"connected"`
+	Uptime     string `json:"uptime,omitempty"`
+	TabsOpened int    `json:"tabs_opened"`
+	ActiveTabs int    `json:"active_tabs"`
+}
+
+// defaultUserAgent is sent unless a FetchProfile overrides it.
+const defaultUserAgent = "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36"
+
+// execAllocatorOptions returns the Chrome launch flags, tuned to be safe for
+// running inside containers.
+func execAllocatorOptions() []chromedp.ExecAllocatorOption {
+	allocOpts := append(chromedp.DefaultExecAllocatorOptions[:],
+		chromedp.Flag("no-sandbox", true),
+		chromedp.Flag("disable-dev-shm-usage", true),
+		chromedp.Flag("disable-breakpad", true),
+		chromedp.Flag("disable-blink-features", "AutomationControlled"),
+		chromedp.Flag("lang", "en-US,en"),
+		chromedp.UserAgent(defaultUserAgent),
+	)
+
+	// Look for a Chrome/Chromium binary in order of preference:
+	// 1. headless-shell (from chromedp/headless-shell Docker image)
+	// 2. google-chrome (default chromedp lookup)
+	// 3. chromium (Debian package)
+	chromePath := ""
+	for _, p := range []string{
+		"/headless-shell/headless-shell",
+	} {
+		if _, err := os.Stat(p); err == nil {
+			chromePath = p
+			break
+		}
+	}
+	if chromePath == "" {
+		for _, name := range []string{"google-chrome", "chromium"} {
+			if p, err := exec.LookPath(name); err == nil {
+				chromePath = p
+				break
+			}
+		}
+	}
+	if chromePath != "" {
+		allocOpts = append(allocOpts, chromedp.ExecPath(chromePath))
+	}
+	return allocOpts
+}
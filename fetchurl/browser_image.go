@@ -21,15 +21,17 @@ import (
 // pass any bot challenges, then use XMLHttpRequest (synchronous-capable) from
 // that page context to download the actual resource.
 func (w *WebFetcher) BrowserDownloadResource(ctx context.Context, targetURL string) (*DownloadedResource, error) {
-	log := slog.Default()
-
 	if targetURL == "" {
 		return nil, fmt.Errorf("missing URL")
 	}
 
-	if allowed, err := ensureURLAllowed(targetURL, w.opts.AllowedURLGlobs, w.opts.DenyURLGlobs); err != nil {
+	if err := w.checkPolicy(ctx, targetURL); err != nil {
+		return nil, err
+	}
+	if err := w.checkRobots(ctx, targetURL, false); err != nil {
 		return nil, err
-	} else if !allowed {
+	}
+	if err := w.checkAddress(ctx, targetURL); err != nil {
 		return nil, err
 	}
 
@@ -38,16 +40,43 @@ func (w *WebFetcher) BrowserDownloadResource(ctx context.Context, targetURL stri
 		timeout = 60 * time.Second
 	}
 
-	tabCtx, tabCancel := chromedp.NewContext(w.browserCtx)
-	defer tabCancel()
-	ctx, cancel := context.WithTimeout(tabCtx, timeout)
-	defer cancel()
+	var resource *DownloadedResource
+	err := w.withTab(ctx, func(tabCtx context.Context) error {
+		tabCtx, cancel := context.WithTimeout(tabCtx, timeout)
+		defer cancel()
+
+		var err error
+		resource, err = w.browserDownloadResource(tabCtx, targetURL)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resource, nil
+}
+
+// browserDownloadResource runs the BrowserDownloadResource steps in an already opened tab.
+func (w *WebFetcher) browserDownloadResource(ctx context.Context, targetURL string) (*DownloadedResource, error) {
+	log := slog.Default()
 
 	// Inject anti-detection scripts before any navigation.
 	if err := chromedp.Run(ctx, stealthSetup()); err != nil {
 		return nil, fmt.Errorf("stealth setup: %w", err)
 	}
 
+	// Apply any matching fetch profile (headers, cookies, user agent, etc.).
+	profile := w.profileFor(targetURL)
+	if err := chromedp.Run(ctx, profile.setup(targetURL)); err != nil {
+		return nil, fmt.Errorf("applying fetch profile: %w", err)
+	}
+
+	// the URL policy, proxy logins, the private network guard and the
+	// profile's headers
+	blocker := w.newRequestBlocker(ctx, ResourceBlocking{}, profile)
+	if err := chromedp.Run(ctx, blocker.setup()); err != nil {
+		return nil, fmt.Errorf("request interception setup: %w", err)
+	}
+
 	// Step 1: Navigate to the host's root page to establish cookies/pass challenges.
 	// We can't navigate to the image URL directly because raw images have no DOM.
 	hostPage := targetURL
@@ -64,9 +93,15 @@ func (w *WebFetcher) BrowserDownloadResource(ctx context.Context, targetURL stri
 		chromedp.Navigate(hostPage),
 		chromedp.WaitReady("body", chromedp.ByQuery),
 	); err != nil {
+		if blockedErr := blocker.err(); blockedErr != nil {
+			return nil, blockedErr
+		}
 		log.Error("browser_image: navigation failed", "hostPage", hostPage, "error", err)
 		return nil, fmt.Errorf("browser navigation to %s: %w", hostPage, err)
 	}
+	if err := blocker.err(); err != nil {
+		return nil, err
+	}
 
 	// Log the current URL after navigation (may have been redirected).
 	var currentURL string
@@ -75,6 +110,7 @@ func (w *WebFetcher) BrowserDownloadResource(ctx context.Context, targetURL stri
 
 	// Step 2: From the page context (with cookies), fetch the image via sync XHR.
 	log.Info("browser_image: fetching image via sync XHR", "targetURL", targetURL)
+	blocker.follow(targetURL)
 
 	var resultJSON string
 	if err := chromedp.Run(ctx,
@@ -107,6 +143,9 @@ func (w *WebFetcher) BrowserDownloadResource(ctx context.Context, targetURL stri
 		log.Error("browser_image: EvaluateAsDevTools failed", "error", err)
 		return nil, fmt.Errorf("browser fetch of %s: %w", targetURL, err)
 	}
+	if err := blocker.err(); err != nil {
+		return nil, err
+	}
 
 	log.Info("browser_image: XHR result", "resultLen", len(resultJSON), "result", resultJSON)
 
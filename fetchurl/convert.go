@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os/exec"
+	"strconv"
 	"strings"
 
 	htmltomarkdown "github.com/JohannesKaufmann/html-to-markdown/v2"
@@ -15,12 +16,42 @@ type MarkdownHeader struct {
 	Title      string
 }
 
+// WebpageToMarkdownYaml converts the page to Markdown with a YAML header. If
+// main content was extracted (ExtractionReadability), only that is converted.
+// Non-HTML documents were already converted when they were fetched.
 func (w *WebFetcher) WebpageToMarkdownYaml(webpage *FetchedWebPage) (string, error) {
-	return HtmlToMarkdownYaml(webpage.Src, map[string]string{
+	if webpage.Markdown != "" {
+		return MarkdownYaml(webpage.Markdown, webpage.frontMatter()), nil
+	}
+	src := webpage.Src
+	if webpage.Content != "" {
+		src = webpage.Content
+	}
+	return HtmlToMarkdownYaml(src, webpage.frontMatter(), w.opts.UsePandoc)
+}
+
+// frontMatter returns the YAML header values for the page. Response details
+// are only included when they are known.
+func (webpage *FetchedWebPage) frontMatter() map[string]string {
+	headers := map[string]string{
 		"target_url":  webpage.TargetURL,
 		"current_url": webpage.CurrentURL,
 		"title":       webpage.Title,
-	}, w.opts.UsePandoc)
+	}
+	if webpage.StatusCode != 0 {
+		headers["status_code"] = strconv.Itoa(webpage.StatusCode)
+	}
+	for k, v := range webpage.Headers {
+		headers[strings.ReplaceAll(k, "-", "_")] = v
+	}
+	if len(webpage.Redirects) > 0 {
+		hops := make([]string, len(webpage.Redirects))
+		for i, hop := range webpage.Redirects {
+			hops[i] = strconv.Quote(hop.String())
+		}
+		headers["redirects"] = "[" + strings.Join(hops, ", ") + "]"
+	}
+	return headers
 }
 
 func HtmlToMarkdownYaml(src string, headers map[string]string, usePandoc bool) (string, error) {
@@ -37,6 +68,11 @@ func HtmlToMarkdownYaml(src string, headers map[string]string, usePandoc bool) (
 		return "", err
 	}
 
+	return MarkdownYaml(markdown, headers), nil
+}
+
+// MarkdownYaml prefixes markdown with a YAML header holding headers.
+func MarkdownYaml(markdown string, headers map[string]string) string {
 	ret := ""
 	if len(headers) > 0 {
 		ret += "---\n"
@@ -47,7 +83,7 @@ func HtmlToMarkdownYaml(src string, headers map[string]string, usePandoc bool) (
 	}
 
 	ret += markdown
-	return ret, nil
+	return ret
 }
 
 func HtmlToMarkdown(html string) (string, error) {
@@ -83,6 +83,12 @@ func (w *WebFetcher) Crawl(ctx context.Context, startURL string, maxDepth int, m
 			continue
 		}
 
+		if err := w.checkRobots(ctx, item.url, true); err != nil {
+			visited[item.url] = true
+			w.opts.Logger.Info("crawl skipped page", "url", item.url, "error", err)
+			continue
+		}
+
 		page, err := w.FetchURL(ctx, item.url, selector)
 		if err != nil {
 			w.opts.Logger.Warn("crawl fetch failed", "url", item.url, "error", err)
@@ -114,6 +120,11 @@ func (w *WebFetcher) Crawl(ctx context.Context, startURL string, maxDepth int, m
 			if visited[norm] {
 				continue
 			}
+			if err := w.checkPolicy(ctx, norm); err != nil {
+				visited[norm] = true
+				w.opts.Logger.Debug("crawl skipped link", "url", norm, "error", err)
+				continue
+			}
 			queue = append(queue, qItem{url: norm, depth: item.depth + 1})
 		}
 	}
@@ -0,0 +1,209 @@
+package fetchurl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+const (
+	// DiagnosticException is the Diagnostic type of uncaught JS exceptions.
+	// Console messages use the console method (log, info, warning, error,
+	// ...).
+	DiagnosticException = "exception"
+
+	// maxDiagnostics caps how many messages are kept per page, and
+	// maxDiagnosticText the length of each one, in characters.
+	maxDiagnostics    = 100
+	maxDiagnosticText = 1000
+)
+
+// Diagnostic is a console message or uncaught exception logged by a page
+// while it was fetched with the browser.
+type Diagnostic struct {
+	Type   string `json:"type"`
+	Text   string `json:"text"`
+	URL    string `json:"url,omitempty"`    // script that logged or threw it
+	Line   int    `json:"line,omitempty"`   // 1-based
+	Column int    `json:"column,omitempty"` // 1-based
+	// BeforeReady is set for messages logged before the page was ready (see
+	// WaitStrategy).
+	BeforeReady bool `json:"before_ready,omitempty"`
+}
+
+func (d Diagnostic) String() string {
+	if d.URL == "" {
+		return fmt.Sprintf("%s: %s", d.Type, d.Text)
+	}
+	return fmt.Sprintf("%s: %s (%s:%d:%d)", d.Type, d.Text, d.URL, d.Line, d.Column)
+}
+
+// PageExceptionError is returned when the page threw uncaught exceptions
+// before it was ready and the caller asked for FailOnExceptions.
+type PageExceptionError struct {
+	URL        string
+	Exceptions []Diagnostic
+}
+
+func (e *PageExceptionError) Error() string {
+	msg := fmt.Sprintf("%s threw %d uncaught exception(s) before it was ready: %s", e.URL, len(e.Exceptions), e.Exceptions[0].Text)
+	if len(e.Exceptions) > 1 {
+		msg += ", ..."
+	}
+	return msg
+}
+
+// exceptionError returns a PageExceptionError if the page threw uncaught
+// exceptions before it was ready.
+func (page *FetchedWebPage) exceptionError() error {
+	var exceptions []Diagnostic
+	for _, d := range page.Diagnostics {
+		if d.Type == DiagnosticException && d.BeforeReady {
+			exceptions = append(exceptions, d)
+		}
+	}
+	if len(exceptions) == 0 {
+		return nil
+	}
+	return &PageExceptionError{URL: page.TargetURL, Exceptions: exceptions}
+}
+
+// diagnosticsRecorder collects a tab's console messages and uncaught
+// exceptions, and logs them at debug level.
+type diagnosticsRecorder struct {
+	mu       sync.Mutex
+	pageURL  string
+	logger   *slog.Logger
+	ready    bool
+	messages []Diagnostic
+	dropped  int
+}
+
+func newDiagnosticsRecorder(pageURL string, logger *slog.Logger) *diagnosticsRecorder {
+	return &diagnosticsRecorder{pageURL: pageURL, logger: logger}
+}
+
+// setup starts listening for Runtime events. It must run before navigating.
+// chromedp enables the Runtime domain for every tab.
+func (r *diagnosticsRecorder) setup() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		chromedp.ListenTarget(ctx, func(ev any) {
+			switch ev := ev.(type) {
+			case *runtime.EventConsoleAPICalled:
+				d := Diagnostic{Type: ev.Type.String(), Text: consoleText(ev.Args)}
+				if ev.StackTrace != nil && len(ev.StackTrace.CallFrames) > 0 {
+					frame := ev.StackTrace.CallFrames[0]
+					d.URL, d.Line, d.Column = frame.URL, int(frame.LineNumber)+1, int(frame.ColumnNumber)+1
+				}
+				r.add(d)
+			case *runtime.EventExceptionThrown:
+				r.add(exceptionDiagnostic(ev.ExceptionDetails))
+			}
+		})
+		return nil
+	})
+}
+
+// markReady is run once the page is ready; later messages aren't
+// BeforeReady.
+func (r *diagnosticsRecorder) markReady() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.ready = true
+		return nil
+	})
+}
+
+func (r *diagnosticsRecorder) add(d Diagnostic) {
+	if utf8.RuneCountInString(d.Text) > maxDiagnosticText {
+		d.Text = string([]rune(d.Text)[:maxDiagnosticText]) + "..."
+	}
+	r.logger.Debug("Browser console", "url", r.pageURL, "type", d.Type, "text", d.Text, "source", d.URL, "line", d.Line)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d.BeforeReady = !r.ready
+	if len(r.messages) >= maxDiagnostics {
+		r.dropped++
+		return
+	}
+	r.messages = append(r.messages, d)
+}
+
+// apply copies what was recorded to page.
+func (r *diagnosticsRecorder) apply(page *FetchedWebPage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.dropped > 0 {
+		r.logger.Debug("Dropped browser console messages", "url", r.pageURL, "dropped", r.dropped)
+	}
+	page.Diagnostics = r.messages
+}
+
+// exceptionDiagnostic describes an uncaught exception by its message (ex:
+// "Uncaught TypeError: x is undefined"), without the stack trace.
+func exceptionDiagnostic(details *runtime.ExceptionDetails) Diagnostic {
+	if details == nil {
+		return Diagnostic{Type: DiagnosticException}
+	}
+	text := details.Text
+	if ex := details.Exception; ex != nil {
+		msg := ex.Description
+		if msg == "" {
+			msg = remoteObjectText(ex)
+		}
+		msg, _, _ = strings.Cut(msg, "\n")
+		if msg != "" {
+			text = strings.TrimSuffix(text, ":") + " " + msg
+		}
+	}
+	d := Diagnostic{
+		Type:   DiagnosticException,
+		Text:   text,
+		URL:    details.URL,
+		Line:   int(details.LineNumber) + 1,
+		Column: int(details.ColumnNumber) + 1,
+	}
+	if d.URL == "" && details.StackTrace != nil && len(details.StackTrace.CallFrames) > 0 {
+		d.URL = details.StackTrace.CallFrames[0].URL
+	}
+	return d
+}
+
+// consoleText joins the console call's arguments the way the DevTools
+// console shows them (without format specifiers being applied).
+func consoleText(args []*runtime.RemoteObject) string {
+	parts := make([]string, 0, len(args))
+	for _, arg := range args {
+		parts = append(parts, remoteObjectText(arg))
+	}
+	return strings.Join(parts, " ")
+}
+
+func remoteObjectText(obj *runtime.RemoteObject) string {
+	if obj == nil {
+		return ""
+	}
+	if obj.UnserializableValue != "" {
+		return obj.UnserializableValue.String()
+	}
+	if len(obj.Value) > 0 {
+		var s string
+		if err := json.Unmarshal(obj.Value, &s); err == nil {
+			return s
+		}
+		return string(obj.Value)
+	}
+	if obj.Description != "" {
+		return obj.Description
+	}
+	return obj.Type.String()
+}
@@ -0,0 +1,103 @@
+package fetchurl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// isHTMLType reports whether mediaType is rendered as a web page. An unknown
+// (empty) type is assumed to be HTML.
+func isHTMLType(mediaType string) bool {
+	switch mediaType {
+	case "", "text/html", "application/xhtml+xml":
+		return true
+	}
+	return false
+}
+
+// documentKind returns how a non-HTML payload is converted: "json", "text",
+// "xml" or "pdf". It returns "" for types that can't be converted.
+func documentKind(mediaType string) string {
+	switch {
+	case mediaType == "application/json" || mediaType == "text/json" || strings.HasSuffix(mediaType, "+json"):
+		return "json"
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return "xml"
+	case mediaType == "application/pdf":
+		return "pdf"
+	case strings.HasPrefix(mediaType, "text/"):
+		return "text"
+	}
+	return ""
+}
+
+// mediaTypeOf returns the media type of a Content-Type header value.
+func mediaTypeOf(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	}
+	return mediaType
+}
+
+// newDocumentPage converts a non-HTML payload into a FetchedWebPage. JSON is
+// pretty-printed in a fenced code block, text and XML are passed through as
+// is, and PDFs are converted with PdfToMarkdown. Src holds the payload as
+// text, and Markdown is used instead of converting Src.
+func newDocumentPage(targetURL string, currentURL string, mediaType string, body []byte) (*FetchedWebPage, error) {
+	page := &FetchedWebPage{
+		TargetURL:  targetURL,
+		CurrentURL: currentURL,
+		Title:      documentTitle(currentURL),
+	}
+
+	switch documentKind(mediaType) {
+	case "json":
+		var pretty bytes.Buffer
+		if err := json.Indent(&pretty, bytes.TrimSpace(body), "", "  "); err != nil {
+			// not valid JSON after all, show it as is
+			pretty.Reset()
+			pretty.Write(body)
+		}
+		page.Src = string(body)
+		page.Markdown = "```json\n" + strings.TrimRight(pretty.String(), "\n") + "\n```\n"
+	case "text", "xml":
+		page.Src = string(body)
+		page.Markdown = string(body)
+	case "pdf":
+		doc, err := PdfToMarkdown(body, PdfOptions{})
+		if err != nil {
+			return nil, err
+		}
+		if doc.Title != "" {
+			page.Title = doc.Title
+		}
+		page.Src = doc.Markdown
+		page.Markdown = doc.Markdown
+	default:
+		return nil, fmt.Errorf("unsupported content type %q", mediaType)
+	}
+	return page, nil
+}
+
+// documentTitle uses the file name in the URL as the title of a document
+// that doesn't have one.
+func documentTitle(docURL string) string {
+	u, err := url.Parse(docURL)
+	if err != nil {
+		return ""
+	}
+	name := path.Base(u.Path)
+	if name == "/" || name == "." {
+		return u.Host
+	}
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		name = unescaped
+	}
+	return name
+}
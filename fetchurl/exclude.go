@@ -0,0 +1,92 @@
+package fetchurl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/chromedp/chromedp"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ValidateExcludeSelectors checks that every exclude selector is a valid CSS
+// selector.
+func ValidateExcludeSelectors(selectors []string) error {
+	for _, sel := range selectors {
+		if _, err := cascadia.Compile(sel); err != nil {
+			return fmt.Errorf("invalid exclude selector %q: %w", sel, err)
+		}
+	}
+	return nil
+}
+
+// RemoveExcluded removes every element matching one of the CSS selectors from
+// the HTML fragment src. It is used for HTML that didn't come straight from
+// the browser (ex: from the cache), where the elements couldn't be removed
+// from the live DOM.
+func RemoveExcluded(src string, selectors []string) (string, error) {
+	if len(selectors) == 0 {
+		return src, nil
+	}
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(src), body)
+	if err != nil {
+		return "", fmt.Errorf("error parsing HTML: %w", err)
+	}
+
+	var out strings.Builder
+	for _, n := range nodes {
+		// matching runs on a tree, so give top-level nodes a parent
+		body.AppendChild(n)
+	}
+	if err := removeMatching(body, selectors); err != nil {
+		return "", err
+	}
+	for n := body.FirstChild; n != nil; n = n.NextSibling {
+		if err := html.Render(&out, n); err != nil {
+			return "", fmt.Errorf("error rendering HTML: %w", err)
+		}
+	}
+	return out.String(), nil
+}
+
+// removeMatching removes the descendants of root that match any of the CSS
+// selectors.
+func removeMatching(root *html.Node, selectors []string) error {
+	for _, s := range selectors {
+		sel, err := cascadia.Compile(s)
+		if err != nil {
+			return fmt.Errorf("invalid exclude selector %q: %w", s, err)
+		}
+		for _, n := range cascadia.QueryAll(root, sel) {
+			if n.Parent != nil && n != root {
+				n.Parent.RemoveChild(n)
+			}
+		}
+	}
+	return nil
+}
+
+// removeExcludedJS removes the elements matching the CSS selectors from the
+// live DOM, before the page HTML is read.
+func removeExcludedJS(selectors []string) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if len(selectors) == 0 {
+			return nil
+		}
+		list, err := json.Marshal(selectors)
+		if err != nil {
+			return err
+		}
+		return chromedp.Evaluate(fmt.Sprintf(`
+			%s.forEach(sel => {
+				try {
+					document.querySelectorAll(sel).forEach(el => el.remove());
+				} catch (e) {}
+			});
+		`, list), nil).Do(ctx)
+	})
+}
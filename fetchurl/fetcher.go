@@ -2,12 +2,13 @@ package fetchurl
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
-	"os"
-	"os/exec"
+	"net/http"
 	"time"
 
+	"github.com/chromedp/cdproto/network"
 	"github.com/chromedp/cdproto/page"
 	"github.com/chromedp/chromedp"
 )
@@ -17,17 +18,23 @@ type WebFetcher struct {
 	// wd      *selenium.WebDriver
 	opts WebFetcherOptions
 	done bool
-	// allocCtx is the chromedp exec allocator. Cancelling it tears down
-	// everything (browser + tabs). browserCtx is a long-lived child of
-	// allocCtx that owns a single shared headless-shell process; per-request
-	// tab contexts are derived from it so we don't relaunch Chrome each call.
-	allocCtx   context.Context
-	allocCan   context.CancelFunc
-	browserCtx context.Context
-	browserCan context.CancelFunc
+	// browser supervises the shared headless Chrome; tabs are opened on it
+	// through withTab, bounded by the tabs pool. nil in FetchModeHTTP.
+	browser *browserSupervisor
 	// lock   sync.Mutex
-	search SearchEngine
-	cache  *CacheDB
+	tabs     *tabPool
+	search   SearchEngine
+	cache    *CacheDB
+	trackers *trackerList
+	// proxy picks the outbound proxy. transport applies it and the guard
+	// to the Go HTTP clients that fetch URLs; apiTransport only the proxy,
+	// for the configured search and summary APIs.
+	proxy        *proxyRouter
+	guard        *ipGuard // nil unless BlockPrivateIPs is set
+	transport    http.RoundTripper
+	apiTransport http.RoundTripper
+	robots       *robotsChecker // nil in RobotsOff mode
+	policy       *URLPolicy     // PolicyRules, then the allow/deny globs
 }
 
 type WebFetcherOptions struct {
@@ -35,8 +42,27 @@ type WebFetcherOptions struct {
 	// WebDriverPort       int
 	ConvertAbsoluteHref bool
 	UsePandoc           bool
+	FetchMode           string           // FetchModeBrowser (default), FetchModeHTTP or FetchModeAuto
+	ExtractionMode      string           // ExtractionSelector (default), ExtractionBody or ExtractionReadability
+	FlattenFrames       bool             // inline iframe and open shadow root content when fetching with a browser
+	Blocking            ResourceBlocking // requests to block while fetching pages with a browser
+	FailOnExceptions    bool             // fail page fetches that throw uncaught JS exceptions before they are ready
+	TrackerBlocklist    []string         // tracker blocklist patterns (nil: the bundled trackers.txt)
+	Proxy               ProxyConfig      // outbound proxy for the browser and the HTTP clients
+	RobotsMode          string           // RobotsOff (default), RobotsCrawl or RobotsAll
+	RobotsUserAgent     string           // product token matched in robots.txt (default: DefaultRobotsUserAgent)
+	// BlockPrivateIPs refuses to fetch URLs whose host is, or resolves
+	// to, a loopback, private, link-local, CGNAT or other reserved address,
+	// other than the AllowedNetworks (CIDRs).
+	BlockPrivateIPs     bool
+	AllowedNetworks     []string
 	PageLoadTimeoutSecs int
 	MaxDownloadBytes    int
+	MaxTabs             int           // max concurrent browser tabs (default: DefaultMaxTabs)
+	TabQueueTimeout     time.Duration // max time to wait for a free tab (default: DefaultTabQueueTimeout)
+	BrowserRecycleTabs  int           // relaunch Chrome after this many tabs (0 = never)
+	BrowserRecycleAfter time.Duration // relaunch Chrome after it has run this long (0 = never)
+	BrowserWSURL        string        // attach to a running Chrome at this DevTools URL instead of launching one
 	Logger              *slog.Logger
 	SearchEngine        string
 	GoogleSearchCx      string
@@ -45,7 +71,10 @@ type WebFetcherOptions struct {
 	CacheExpires        time.Duration
 	AllowedURLGlobs     []string
 	DenyURLGlobs        []string
+	PolicyRules         []PolicyRule // checked before the allow/deny globs (first match wins)
+	PolicyDefault       string       // action for URLs no rule matches (see NewURLPolicy)
 	UrlSelectors        []UrlSelector
+	Profiles            []FetchProfile // per-URL-glob browser settings (first match wins)
 	SummarizeBaseURL    string
 	SummarizeApiKey     string
 	SummarizeModel      string
@@ -56,6 +85,34 @@ type WebFetcherOptions struct {
 type UrlSelector struct {
 	Url      string
 	Selector string
+	Wait     WaitStrategy // readiness strategy for matching pages (optional)
+	Exclude  []string     // CSS selectors of elements to remove before conversion (optional)
+}
+
+// FetchOptions are per-call settings for FetchURLWithOptions. Empty fields
+// fall back to the matching UrlSelectors entry, then the matching
+// FetchProfile, and then the defaults.
+type FetchOptions struct {
+	Selector string
+	Wait     WaitStrategy
+	Actions  []PageAction // run after any actions from the matching FetchProfile
+	Exclude  []string     // added to the Exclude list of the matching UrlSelectors entry
+	// AllowErrorPages returns pages served with a 4xx or 5xx status instead
+	// of an HTTPStatusError.
+	AllowErrorPages bool
+	// FlattenFrames inlines the content of iframes and open shadow roots,
+	// even if WebFetcherOptions.FlattenFrames isn't set.
+	FlattenFrames bool
+	// Blocking replaces the request blocking rules of the matching
+	// FetchProfile and WebFetcherOptions.
+	Blocking *ResourceBlocking
+	// HAR records the browser tab's network activity. The page is always
+	// fetched with the browser, and not from the cache.
+	HAR *HARRecorder
+	// FailOnExceptions returns a PageExceptionError if the page threw
+	// uncaught JS exceptions before it was ready, even if
+	// WebFetcherOptions.FailOnExceptions isn't set.
+	FailOnExceptions bool
 }
 
 type FetchedWebPage struct {
@@ -63,6 +120,20 @@ type FetchedWebPage struct {
 	CurrentURL string `json:"current_url"`
 	Title      string `json:"title"`
 	Src        string `json:"html"`
+	// Content is the main content extracted from Src (ExtractionReadability
+	// only). Src is kept as is so crawling still sees every link.
+	Content string `json:"content,omitempty"`
+	// Markdown is set for non-HTML documents (JSON, text, XML, PDF), which
+	// are converted when they are fetched. Src then holds the document text.
+	Markdown string `json:"markdown,omitempty"`
+	// StatusCode, Headers (content-type, last-modified, etag) and Redirects
+	// describe the main document's response. StatusCode is 0 if unknown.
+	StatusCode int               `json:"status_code,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Redirects  []RedirectHop     `json:"redirects,omitempty"`
+	// Diagnostics are the console messages and uncaught exceptions logged
+	// while the page was fetched with the browser.
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
 }
 
 type FetchedWebPageResult struct {
@@ -81,6 +152,37 @@ func NewWebFetcher(opts WebFetcherOptions) (*WebFetcher, error) {
 		opts.PageLoadTimeoutSecs = 30
 	}
 
+	if opts.FetchMode == "" {
+		opts.FetchMode = FetchModeBrowser
+	}
+	if !ValidFetchMode(opts.FetchMode) {
+		return nil, fmt.Errorf("invalid fetch mode %q (expected browser, http or auto)", opts.FetchMode)
+	}
+
+	if opts.ExtractionMode == "" {
+		opts.ExtractionMode = ExtractionSelector
+	}
+	if !ValidExtractionMode(opts.ExtractionMode) {
+		return nil, fmt.Errorf("invalid extraction mode %q (expected body, selector or readability)", opts.ExtractionMode)
+	}
+
+	if opts.RobotsMode == "" {
+		opts.RobotsMode = RobotsOff
+	}
+	if !ValidRobotsMode(opts.RobotsMode) {
+		return nil, fmt.Errorf("invalid robots mode %q (expected off, crawl or all)", opts.RobotsMode)
+	}
+	if opts.RobotsUserAgent == "" {
+		opts.RobotsUserAgent = DefaultRobotsUserAgent
+	}
+
+	if opts.MaxTabs <= 0 {
+		opts.MaxTabs = DefaultMaxTabs
+	}
+	if opts.TabQueueTimeout <= 0 {
+		opts.TabQueueTimeout = DefaultTabQueueTimeout
+	}
+
 	if opts.Logger == nil {
 		opts.Logger = slog.New(slog.DiscardHandler)
 	}
@@ -95,11 +197,39 @@ func NewWebFetcher(opts WebFetcherOptions) (*WebFetcher, error) {
 		cache = cacheDB
 	}
 
+	trackers, err := newTrackerList(opts.TrackerBlocklist)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := NewURLPolicy(opts.PolicyRules, opts.AllowedURLGlobs, opts.DenyURLGlobs, opts.PolicyDefault)
+	if err != nil {
+		return nil, err
+	}
+
+	proxy, err := newProxyRouter(opts.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	guard, err := newIPGuard(opts.BlockPrivateIPs, opts.AllowedNetworks, proxy)
+	if err != nil {
+		return nil, err
+	}
+	transport := newTransport(proxy, guard)
+	apiTransport := newTransport(proxy, nil)
+
+	var robots *robotsChecker
+	if opts.RobotsMode != RobotsOff {
+		robots = newRobotsChecker(opts.RobotsUserAgent, &http.Client{Transport: transport}, cache, opts.Logger)
+	}
+
 	var search SearchEngine
 
 	if opts.SearchEngine == "google_custom" {
 		if opts.GoogleSearchCx != "" && opts.GoogleSearchKey != "" {
-			search = NewGoogleCustomSearch(opts.GoogleSearchCx, opts.GoogleSearchKey)
+			engine := NewGoogleCustomSearch(opts.GoogleSearchCx, opts.GoogleSearchKey)
+			engine.transport = apiTransport
+			search = engine
 		} else {
 			opts.Logger.Info("missing Google cx and/or api key values, disabling search")
 		}
@@ -107,75 +237,68 @@ func NewWebFetcher(opts WebFetcherOptions) (*WebFetcher, error) {
 		opts.Logger.Info("No valid search_engine configured.")
 	}
 
-	// Set up a shared Chrome allocator with container-safe flags.
-	allocOpts := append(chromedp.DefaultExecAllocatorOptions[:],
-		chromedp.Flag("no-sandbox", true),
-		chromedp.Flag("disable-dev-shm-usage", true),
-		chromedp.Flag("disable-breakpad", true),
-		chromedp.Flag("disable-blink-features", "AutomationControlled"),
-		chromedp.Flag("lang", "en-US,en"),
-		chromedp.UserAgent("Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36"),
-	)
-
-	// Look for a Chrome/Chromium binary in order of preference:
-	// 1. headless-shell (from chromedp/headless-shell Docker image)
-	// 2. google-chrome (default chromedp lookup)
-	// 3. chromium (Debian package)
-	chromePath := ""
-	for _, p := range []string{
-		"/headless-shell/headless-shell",
-	} {
-		if _, err := os.Stat(p); err == nil {
-			chromePath = p
-			break
-		}
-	}
-	if chromePath == "" {
-		for _, name := range []string{"google-chrome", "chromium"} {
-			if p, err := exec.LookPath(name); err == nil {
-				chromePath = p
-				break
-			}
+	// Chrome isn't needed (or even installed) in http mode
+	var browser *browserSupervisor
+	if opts.FetchMode != FetchModeHTTP {
+		if proxy != nil && opts.BrowserWSURL != "" {
+			opts.Logger.Warn("the proxy can't be set on a remote browser, configure it where the browser is launched")
+		}
+		if proxy.socksLogin() {
+			opts.Logger.Warn("Chrome can't log in to SOCKS proxies, browser requests through them will fail")
+		}
+		browser = newBrowserSupervisor(opts.BrowserWSURL, opts.BrowserRecycleTabs, opts.BrowserRecycleAfter, proxy.chromeFlags(), opts.Logger)
+		if err := browser.start(); err != nil {
+			return nil, err
 		}
-	}
-	if chromePath != "" {
-		allocOpts = append(allocOpts, chromedp.ExecPath(chromePath))
-	}
-
-	allocCtx, allocCan := chromedp.NewExecAllocator(context.Background(), allocOpts...)
-
-	// Launch a single browser up front; tabs are derived from this context.
-	browserCtx, browserCan := chromedp.NewContext(allocCtx)
-	if err := chromedp.Run(browserCtx); err != nil {
-		browserCan()
-		allocCan()
-		return nil, fmt.Errorf("starting headless browser: %w", err)
 	}
 
 	return &WebFetcher{
-		opts:       opts,
-		search:     search,
-		cache:      cache,
-		allocCtx:   allocCtx,
-		allocCan:   allocCan,
-		browserCtx: browserCtx,
-		browserCan: browserCan,
+		opts:         opts,
+		tabs:         newTabPool(opts.MaxTabs, opts.TabQueueTimeout, opts.Logger),
+		browser:      browser,
+		search:       search,
+		cache:        cache,
+		trackers:     trackers,
+		proxy:        proxy,
+		guard:        guard,
+		transport:    transport,
+		apiTransport: apiTransport,
+		robots:       robots,
+		policy:       policy,
 	}, nil
 }
 
+// httpClient returns a client for fetching URLs, through the configured
+// proxy and guard. Redirects are checked against the URL policy.
+func (w *WebFetcher) httpClient() *http.Client {
+	return &http.Client{Transport: w.transport, CheckRedirect: w.checkRedirect}
+}
+
 func (w *WebFetcher) HasSearch() bool {
 	return w.search != nil
 }
 
+// BrowserStatus reports which browser mode is active (local, remote or
+// disabled) and whether the browser is currently connected.
+func (w *WebFetcher) BrowserStatus() BrowserStatus {
+	if w.browser == nil {
+		return BrowserStatus{Mode: BrowserModeDisabled}
+	}
+	return w.browser.status()
+}
+
+// HasBrowser reports whether headless Chrome is available (i.e. the fetch
+// mode isn't http).
+func (w *WebFetcher) HasBrowser() bool {
+	return w.browser != nil
+}
+
 func (w *WebFetcher) Stop() {
 	// if w.service != nil {
 	// 	w.service.Stop()
 	// }
-	if w.browserCan != nil {
-		w.browserCan()
-	}
-	if w.allocCan != nil {
-		w.allocCan()
+	if w.browser != nil {
+		w.browser.stop()
 	}
 	if w.cache != nil {
 		w.cache.Close()
@@ -191,6 +314,38 @@ func (w *WebFetcher) Start() error {
 	return nil
 }
 
+// withTab runs fn in a new browser tab, waiting for a free slot in the tab
+// pool first. If the browser dies while fn is running, it is relaunched and fn
+// is retried once on the new browser.
+func (w *WebFetcher) withTab(ctx context.Context, fn func(tabCtx context.Context) error) error {
+	if w.browser == nil {
+		return fmt.Errorf("headless browser is disabled (fetch mode is %s)", w.opts.FetchMode)
+	}
+
+	release, err := w.tabs.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	for attempt := 1; ; attempt++ {
+		inst, err := w.browser.acquire(ctx)
+		if err != nil {
+			return err
+		}
+		tabCtx, tabCancel := chromedp.NewContext(inst.browserCtx)
+		err = fn(tabCtx)
+		tabCancel()
+		dead := inst.dead()
+		w.browser.release(inst)
+
+		if err == nil || !dead || attempt > 1 {
+			return err
+		}
+		w.opts.Logger.Warn("headless browser died during request, retrying", "error", err)
+	}
+}
+
 // stealthSetup returns a chromedp.Action that injects anti-detection scripts
 // via Page.addScriptToEvaluateOnNewDocument. This must be run once per browser
 // context, before any navigation — it applies to all subsequent page loads.
@@ -280,7 +435,7 @@ if (window.Permissions && Permissions.prototype.query) {
 
 // func (w *WebFetcher) FetchURL(ctx context.Context, targetURL string, selector string) (*FetchedWebPage, error) {
 
-// 	// check allow/disallow lists first
+// 	// check the URL policy first
 // 	if allowed, err := ensureURLAllowed(targetURL, w.opts.AllowedURLGlobs, w.opts.DenyURLGlobs); err != nil {
 // 		return nil, err
 // 	} else if !allowed {
@@ -474,50 +629,256 @@ func (w *WebFetcher) SearchJSON(ctx context.Context, query string) ([]SearchResu
 }
 
 func (w *WebFetcher) FetchURL(ctx context.Context, targetURL string, selector string) (*FetchedWebPage, error) {
+	return w.FetchURLWithOptions(ctx, targetURL, FetchOptions{Selector: selector})
+}
 
-	tabCtx, tabCancel := chromedp.NewContext(w.browserCtx)
-	defer tabCancel()
-	ctx, cancel := context.WithTimeout(tabCtx, time.Duration(w.opts.PageLoadTimeoutSecs)*time.Second)
-	defer cancel()
+func (w *WebFetcher) FetchURLWithOptions(ctx context.Context, targetURL string, fetchOpts FetchOptions) (*FetchedWebPage, error) {
 
-	// check allow/disallow lists first
-	if allowed, err := ensureURLAllowed(targetURL, w.opts.AllowedURLGlobs, w.opts.DenyURLGlobs); err != nil {
+	// check the URL policy first
+	if err := w.checkPolicy(ctx, targetURL); err != nil {
 		return nil, err
-	} else if !allowed {
+	}
+	if err := w.checkAddress(ctx, targetURL); err != nil {
 		return nil, err
 	}
 
 	// see if we have a pre-configured selector for this URL
-	if selector == "" {
-		for _, sel := range w.opts.UrlSelectors {
-			if match, _ := matchGlobList(targetURL, []string{sel.Url}); match {
-				selector = sel.Selector
-				break
-			}
-		}
+	selector := fetchOpts.Selector
+	if selector == "" && w.opts.ExtractionMode != ExtractionBody {
+		selector = w.selectorFor(targetURL)
 	}
 
+	// without a selector, readability mode picks out the main content
+	extractMain := selector == "" && w.opts.ExtractionMode == ExtractionReadability
+
 	if selector == "" {
 		selector = "body"
 	}
 
-	if w.cache != nil {
+	profile := w.profileFor(targetURL)
+
+	wait := fetchOpts.Wait
+	if wait.IsZero() {
+		wait = w.waitStrategyFor(targetURL)
+	}
+	if wait.IsZero() && profile != nil {
+		wait = profile.Wait
+	}
+
+	var actions []PageAction
+	if profile != nil {
+		actions = append(actions, profile.Actions...)
+	}
+	actions = append(actions, fetchOpts.Actions...)
+
+	exclude := append(w.excludeSelectorsFor(targetURL), fetchOpts.Exclude...)
+
+	flatten := w.opts.FlattenFrames || fetchOpts.FlattenFrames
+	failOnExceptions := w.opts.FailOnExceptions || fetchOpts.FailOnExceptions
+	blocking := w.blockingFor(profile, fetchOpts.Blocking)
+
+	// per-call actions change what the page looks like, so the cached copy
+	// (keyed on URL and selector) can't be used, and neither can it when
+	// frames are flattened, resources blocked or a wait strategy chosen for
+	// this call only, or the network activity is being recorded. Per-call
+	// excludes can be applied to the cached copy, but the stripped page
+	// mustn't be cached.
+	useCache := w.cache != nil && len(fetchOpts.Actions) == 0 && fetchOpts.HAR == nil && flatten == w.opts.FlattenFrames && fetchOpts.Blocking == nil && fetchOpts.Wait.IsZero()
+	putCache := useCache && len(fetchOpts.Exclude) == 0
+
+	if useCache {
 		if page, ok, err := w.cache.GetWebPage(ctx, targetURL, selector); err == nil && ok {
 			w.opts.Logger.Debug("Returning web page from cache")
+			if page.Markdown == "" {
+				if page.Src, err = RemoveExcluded(page.Src, exclude); err != nil {
+					return nil, err
+				}
+			}
+			if page.StatusCode >= 400 && !fetchOpts.AllowErrorPages {
+				return nil, &HTTPStatusError{URL: targetURL, StatusCode: page.StatusCode}
+			}
+			if failOnExceptions {
+				if err := page.exceptionError(); err != nil {
+					return nil, err
+				}
+			}
+			// the URL policy may have changed since it was cached
+			if err := w.checkFinalURL(ctx, targetURL, page.CurrentURL); err != nil {
+				return nil, err
+			}
+			w.extractContent(page, extractMain)
 			return page, nil
 		} else if err != nil {
 			w.opts.Logger.Warn("web cache get failed", "error", err)
 		}
 	}
 
+	// only pages requested from the site are subject to robots.txt (and
+	// its Crawl-delay), not cached copies
+	if err := w.checkRobots(ctx, targetURL, false); err != nil {
+		return nil, err
+	}
+
+	var webpage *FetchedWebPage
+	var err error
+	switch {
+	case len(fetchOpts.Actions) > 0 && w.opts.FetchMode == FetchModeHTTP:
+		return nil, fmt.Errorf("page actions need a browser (fetch mode is %s)", w.opts.FetchMode)
+	case fetchOpts.HAR != nil && w.opts.FetchMode == FetchModeHTTP:
+		return nil, fmt.Errorf("HAR capture needs a browser (fetch mode is %s)", w.opts.FetchMode)
+	case (len(actions) > 0 || fetchOpts.HAR != nil) && w.opts.FetchMode != FetchModeHTTP:
+		// actions and HAR capture need the browser, even in auto mode
+		webpage, err = w.fetchURLBrowser(ctx, targetURL, selector, exclude, wait, profile, actions, flatten, blocking, fetchOpts.HAR)
+	case w.opts.FetchMode == FetchModeHTTP:
+		var needsBrowser string
+		webpage, needsBrowser, err = w.fetchURLHTTP(ctx, targetURL, selector, exclude, profile)
+		if err == nil && webpage == nil {
+			err = fmt.Errorf("unable to fetch %s without a browser: %s", targetURL, needsBrowser)
+		} else if needsBrowser != "" {
+			w.opts.Logger.Debug("Page may need a browser to render", "url", targetURL, "reason", needsBrowser)
+		}
+	case w.opts.FetchMode == FetchModeAuto:
+		var needsBrowser string
+		webpage, needsBrowser, err = w.fetchURLHTTP(ctx, targetURL, selector, exclude, profile)
+		var statusErr *HTTPStatusError
+		if err != nil && ctx.Err() == nil && !errors.As(err, &statusErr) {
+			needsBrowser = err.Error()
+		}
+		if webpage != nil && webpage.StatusCode >= 400 {
+			// the browser would get the same error page
+			needsBrowser = ""
+		}
+		if needsBrowser != "" {
+			w.opts.Logger.Debug("Falling back to headless browser", "url", targetURL, "reason", needsBrowser)
+			webpage, err = w.fetchURLBrowser(ctx, targetURL, selector, exclude, wait, profile, nil, flatten, blocking, nil)
+		}
+	default:
+		webpage, err = w.fetchURLBrowser(ctx, targetURL, selector, exclude, wait, profile, nil, flatten, blocking, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := w.checkFinalURL(ctx, targetURL, webpage.CurrentURL); err != nil {
+		return nil, err
+	}
+	if webpage.StatusCode >= 400 && !fetchOpts.AllowErrorPages {
+		return nil, &HTTPStatusError{URL: targetURL, StatusCode: webpage.StatusCode}
+	}
+
+	w.extractContent(webpage, extractMain)
+
+	// error pages are usually temporary, so they aren't cached
+	if putCache && webpage.StatusCode < 400 {
+		if err := w.cache.PutWebPage(ctx, targetURL, selector, webpage); err != nil {
+			w.opts.Logger.Warn("web cache put failed", "error", err)
+		}
+	}
+
+	// the page is cached along with its diagnostics, so later calls fail
+	// the same way
+	if failOnExceptions {
+		if err := webpage.exceptionError(); err != nil {
+			return nil, err
+		}
+	}
+
+	return webpage, nil
+
+}
+
+// extractContent sets page.Content to the page's main content when
+// extractMain is set, and clears it otherwise.
+func (w *WebFetcher) extractContent(page *FetchedWebPage, extractMain bool) {
+	page.Content = ""
+	if !extractMain || page.Markdown != "" {
+		return
+	}
+	content, err := ExtractMainContent(page.Src)
+	if err != nil {
+		w.opts.Logger.Warn("main content extraction failed, using full page", "url", page.TargetURL, "error", err)
+		return
+	}
+	page.Content = content
+}
+
+// fetchURLBrowser renders targetURL in a headless Chrome tab, runs any page
+// actions, removes excluded elements, and returns the outer HTML of the
+// element matching selector. If flatten is set, the content of iframes and
+// open shadow roots is inlined (see flattenedHTML). Requests matching the
+// blocking rules are failed before they are sent, and the tab's network
+// activity is recorded in har if it is set. Console messages and uncaught
+// exceptions are kept in the page's Diagnostics. Non-HTML documents are
+// converted with newDocumentPage.
+func (w *WebFetcher) fetchURLBrowser(ctx context.Context, targetURL string, selector string, exclude []string, wait WaitStrategy, profile *FetchProfile, actions []PageAction, flatten bool, blocking ResourceBlocking, har *HARRecorder) (*FetchedWebPage, error) {
 	var htmlSrc string
 	var title string
 	var currentUrl string
+	// withTab retries once if the browser crashed, so these are reset for
+	// each attempt
+	var recorder *responseRecorder
+	var diagnostics *diagnosticsRecorder
+	var docType string
+	var docBody []byte
+
+	err := w.withTab(ctx, func(tabCtx context.Context) error {
+		tabCtx, cancel := context.WithTimeout(tabCtx, time.Duration(w.opts.PageLoadTimeoutSecs)*time.Second)
+		defer cancel()
+
+		recorder = &responseRecorder{}
+		diagnostics = newDiagnosticsRecorder(targetURL, w.opts.Logger)
+		docType, docBody = "", nil
+		waiter := newReadinessWaiter(wait, DefaultWaitTimeout, w.opts.Logger)
+		blocker := w.newRequestBlocker(ctx, blocking, profile)
+		defer blocker.logBlocked(targetURL)
+		// response bodies are read from the tab, so wait for them before
+		// it is closed
+		defer har.wait()
+
+		err := chromedp.Run(tabCtx,
+			stealthSetup(),
+			profile.setup(targetURL),
+			blocker.setup(),
+			har.setup(targetURL, profile),
+			recorder.setup(),
+			diagnostics.setup(),
+			waiter.setup(),
+			chromedp.Navigate(targetURL),
+		)
+		if blockedErr := blocker.err(); blockedErr != nil {
+			return blockedErr
+		}
 
-	if err := chromedp.Run(ctx,
-		stealthSetup(),
-		chromedp.Navigate(targetURL),
-		chromedp.Evaluate(`
+		// Non-HTML documents are shown in one of Chrome's viewers, or handed
+		// to the download manager (which aborts the navigation), so their
+		// HTML is useless. Take the response body instead.
+		requestID, mediaType, isDoc := recorder.document()
+		if err != nil && !isDoc {
+			return err
+		}
+		if isDoc {
+			docType = mediaType
+			if body, err := network.GetResponseBody(requestID).Do(tabCtx); err == nil {
+				docBody = body
+			}
+			return nil
+		}
+
+		capture := chromedp.OuterHTML(selector, &htmlSrc, chromedp.ByQuery)
+		if flatten {
+			capture = chromedp.Tasks{
+				chromedp.WaitReady(selector, chromedp.ByQuery),
+				flattenedHTML(selector, func(pageURL, frameURL string) bool {
+					return w.frameAllowed(ctx, pageURL, frameURL)
+				}, w.opts.Logger, &htmlSrc),
+			}
+		}
+
+		err = chromedp.Run(tabCtx,
+			waiter.wait(),
+			diagnostics.markReady(),
+			w.runPageActions(actions),
+			removeExcludedJS(exclude),
+			chromedp.Evaluate(`
 		if (document.body) {
 			const links = document.body.querySelectorAll('a');
 			const images = document.body.querySelectorAll('img');
@@ -531,66 +892,143 @@ func (w *WebFetcher) FetchURL(ctx context.Context, targetURL string, selector st
 			});
 		}
     	`, nil),
-		chromedp.OuterHTML(selector, &htmlSrc, chromedp.ByQuery),
-		chromedp.Title(&title),
-		chromedp.Location(&currentUrl),
-	); err != nil {
+			capture,
+			chromedp.Title(&title),
+			chromedp.Location(&currentUrl),
+		)
+		if blockedErr := blocker.err(); blockedErr != nil {
+			return blockedErr
+		}
+		return err
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	webpage := &FetchedWebPage{Title: title, TargetURL: targetURL, CurrentURL: currentUrl, Src: htmlSrc}
+	// the excluded elements were only removed from the main document
+	if flatten && docType == "" {
+		if htmlSrc, err = RemoveExcluded(htmlSrc, exclude); err != nil {
+			return nil, err
+		}
+	}
 
-	if w.cache != nil {
-		if err := w.cache.PutWebPage(ctx, targetURL, selector, webpage); err != nil {
-			w.opts.Logger.Warn("web cache put failed", "error", err)
+	if docType != "" {
+		if docBody == nil {
+			// the body isn't available from the browser (ex: a download), so
+			// fetch it again without one
+			w.opts.Logger.Debug("Fetching document without the browser", "url", targetURL, "content_type", docType)
+			webpage, needsBrowser, err := w.fetchURLHTTP(ctx, targetURL, selector, exclude, profile)
+			if err == nil && webpage == nil {
+				err = fmt.Errorf("unable to fetch %s document: %s", docType, needsBrowser)
+			}
+			return webpage, err
 		}
+		webpage, err := newDocumentPage(targetURL, recorder.url, docType, docBody)
+		if err != nil {
+			return nil, err
+		}
+		recorder.apply(webpage)
+		diagnostics.apply(webpage)
+		return webpage, nil
 	}
 
+	webpage := &FetchedWebPage{Title: title, TargetURL: targetURL, CurrentURL: currentUrl, Src: htmlSrc}
+	recorder.apply(webpage)
+	diagnostics.apply(webpage)
 	return webpage, nil
-
 }
 
 func (w *WebFetcher) FetchURLPNG(ctx context.Context, targetURL string, selector string) ([]byte, error) {
 
-	tabCtx, tabCancel := chromedp.NewContext(w.browserCtx)
-	defer tabCancel()
-	ctx, cancel := context.WithTimeout(tabCtx, time.Duration(w.opts.PageLoadTimeoutSecs)*time.Second)
-	defer cancel()
-
-	// check allow/disallow lists first
-	if allowed, err := ensureURLAllowed(targetURL, w.opts.AllowedURLGlobs, w.opts.DenyURLGlobs); err != nil {
+	// check the URL policy first
+	if err := w.checkPolicy(ctx, targetURL); err != nil {
+		return nil, err
+	}
+	if err := w.checkRobots(ctx, targetURL, false); err != nil {
 		return nil, err
-	} else if !allowed {
+	}
+	if err := w.checkAddress(ctx, targetURL); err != nil {
 		return nil, err
 	}
 
 	// see if we have a pre-configured selector for this URL
 	if selector == "" {
-		for _, sel := range w.opts.UrlSelectors {
-			if match, _ := matchGlobList(targetURL, []string{sel.Url}); match {
-				selector = sel.Selector
-				break
-			}
-		}
+		selector = w.selectorFor(targetURL)
 	}
 
-	var act chromedp.Action
 	var buf []byte
 
-	if selector == "" {
-		act = chromedp.FullScreenshot(&buf, 100)
-	} else {
-		act = chromedp.Screenshot(selector, &buf)
-	}
+	err := w.withTab(ctx, func(tabCtx context.Context) error {
+		tabCtx, cancel := context.WithTimeout(tabCtx, time.Duration(w.opts.PageLoadTimeoutSecs)*time.Second)
+		defer cancel()
 
-	if err := chromedp.Run(ctx,
-		stealthSetup(),
-		chromedp.Navigate(targetURL),
-		act,
-	); err != nil {
+		var act chromedp.Action
+		if selector == "" {
+			act = chromedp.FullScreenshot(&buf, 100)
+		} else {
+			act = chromedp.Screenshot(selector, &buf)
+		}
+
+		profile := w.profileFor(targetURL)
+		blocker := w.newRequestBlocker(ctx, ResourceBlocking{}, profile)
+		err := chromedp.Run(tabCtx,
+			stealthSetup(),
+			profile.setup(targetURL),
+			blocker.setup(),
+			chromedp.Navigate(targetURL),
+			act,
+		)
+		if blockedErr := blocker.err(); blockedErr != nil {
+			return blockedErr
+		}
+		return err
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	return buf, nil
 
 }
+
+// selectorFor returns the selector of the first UrlSelectors entry matching
+// targetURL, or "" if there is none.
+func (w *WebFetcher) selectorFor(targetURL string) string {
+	for _, sel := range w.opts.UrlSelectors {
+		if sel.Selector == "" {
+			continue
+		}
+		if match, _ := matchGlobList(targetURL, []string{sel.Url}); match {
+			return sel.Selector
+		}
+	}
+	return ""
+}
+
+// excludeSelectorsFor returns the exclude selectors of the first UrlSelectors
+// entry matching targetURL that sets any.
+func (w *WebFetcher) excludeSelectorsFor(targetURL string) []string {
+	for _, sel := range w.opts.UrlSelectors {
+		if len(sel.Exclude) == 0 {
+			continue
+		}
+		if match, _ := matchGlobList(targetURL, []string{sel.Url}); match {
+			return append([]string(nil), sel.Exclude...)
+		}
+	}
+	return nil
+}
+
+// waitStrategyFor returns the readiness strategy of the first UrlSelectors
+// entry matching targetURL that sets one.
+func (w *WebFetcher) waitStrategyFor(targetURL string) WaitStrategy {
+	for _, sel := range w.opts.UrlSelectors {
+		if sel.Wait.IsZero() {
+			continue
+		}
+		if match, _ := matchGlobList(targetURL, []string{sel.Url}); match {
+			return sel.Wait
+		}
+	}
+	return WaitStrategy{}
+}
@@ -21,15 +21,17 @@ import (
 // cookies and pass bot-detection challenges before fetching the target file.
 // Otherwise it navigates to the host root of targetURL.
 func (w *WebFetcher) BrowserDownloadFile(ctx context.Context, targetURL, warmupURL string) (*DownloadedResource, error) {
-	log := slog.Default()
-
 	if targetURL == "" {
 		return nil, fmt.Errorf("missing URL")
 	}
 
-	if allowed, err := ensureURLAllowed(targetURL, w.opts.AllowedURLGlobs, w.opts.DenyURLGlobs); err != nil {
+	if err := w.checkPolicy(ctx, targetURL); err != nil {
+		return nil, err
+	}
+	if err := w.checkRobots(ctx, targetURL, false); err != nil {
 		return nil, err
-	} else if !allowed {
+	}
+	if err := w.checkAddress(ctx, targetURL); err != nil {
 		return nil, err
 	}
 
@@ -38,16 +40,43 @@ func (w *WebFetcher) BrowserDownloadFile(ctx context.Context, targetURL, warmupU
 		timeout = 60 * time.Second
 	}
 
-	tabCtx, tabCancel := chromedp.NewContext(w.browserCtx)
-	defer tabCancel()
-	ctx, cancel := context.WithTimeout(tabCtx, timeout)
-	defer cancel()
+	var resource *DownloadedResource
+	err := w.withTab(ctx, func(tabCtx context.Context) error {
+		tabCtx, cancel := context.WithTimeout(tabCtx, timeout)
+		defer cancel()
+
+		var err error
+		resource, err = w.browserDownloadFile(tabCtx, targetURL, warmupURL)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resource, nil
+}
+
+// browserDownloadFile runs the BrowserDownloadFile steps in an already opened tab.
+func (w *WebFetcher) browserDownloadFile(ctx context.Context, targetURL, warmupURL string) (*DownloadedResource, error) {
+	log := slog.Default()
 
 	// Inject anti-detection scripts before any navigation.
 	if err := chromedp.Run(ctx, stealthSetup()); err != nil {
 		return nil, fmt.Errorf("stealth setup: %w", err)
 	}
 
+	// Apply any matching fetch profile (headers, cookies, user agent, etc.).
+	profile := w.profileFor(targetURL)
+	if err := chromedp.Run(ctx, profile.setup(targetURL)); err != nil {
+		return nil, fmt.Errorf("applying fetch profile: %w", err)
+	}
+
+	// the URL policy, proxy logins, the private network guard and the
+	// profile's headers
+	blocker := w.newRequestBlocker(ctx, ResourceBlocking{}, profile)
+	if err := chromedp.Run(ctx, blocker.setup()); err != nil {
+		return nil, fmt.Errorf("request interception setup: %w", err)
+	}
+
 	// Navigate to a warmup page to establish cookies/pass challenges.
 	// Use the provided warmup URL, or fall back to the host root.
 	navPage := warmupURL
@@ -64,9 +93,15 @@ func (w *WebFetcher) BrowserDownloadFile(ctx context.Context, targetURL, warmupU
 		chromedp.Navigate(navPage),
 		chromedp.WaitReady("body", chromedp.ByQuery),
 	); err != nil {
+		if blockedErr := blocker.err(); blockedErr != nil {
+			return nil, blockedErr
+		}
 		log.Error("browser_file_download: navigation failed", "navPage", navPage, "error", err)
 		return nil, fmt.Errorf("browser navigation to %s: %w", navPage, err)
 	}
+	if err := blocker.err(); err != nil {
+		return nil, err
+	}
 
 	var currentURL string
 	_ = chromedp.Run(ctx, chromedp.Location(&currentURL))
@@ -105,6 +140,7 @@ func (w *WebFetcher) BrowserDownloadFile(ctx context.Context, targetURL, warmupU
 
 	// From the page context (with cookies), fetch the file via sync XHR.
 	log.Info("browser_file_download: fetching file via sync XHR", "targetURL", targetURL)
+	blocker.follow(targetURL)
 
 	var resultJSON string
 	if err := chromedp.Run(ctx,
@@ -137,6 +173,9 @@ func (w *WebFetcher) BrowserDownloadFile(ctx context.Context, targetURL, warmupU
 		log.Error("browser_file_download: EvaluateAsDevTools failed", "error", err)
 		return nil, fmt.Errorf("browser fetch of %s: %w", targetURL, err)
 	}
+	if err := blocker.err(); err != nil {
+		return nil, err
+	}
 
 	var result struct {
 		Data  string `json:"data"`
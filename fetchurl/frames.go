@@ -0,0 +1,259 @@
+package fetchurl
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/url"
+	"strings"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/dom"
+	"github.com/chromedp/chromedp"
+)
+
+// voidElements have no closing tag.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"source": true, "track": true, "wbr": true,
+}
+
+// rawTextElements hold text that mustn't be escaped.
+var rawTextElements = map[string]bool{
+	"script": true, "style": true, "noscript": true, "textarea": true,
+	"xmp": true, "iframe": true, "noembed": true, "noframes": true,
+	"plaintext": true,
+}
+
+// flattenedHTML returns the HTML of the element matching selector with
+// the content of iframes and open shadow roots inlined, as it is rendered.
+// chromedp.OuterHTML only sees the light DOM of the main document.
+//
+// The DOM is read through CDP, so same-origin frames are included even if a
+// script couldn't reach them. Each inlined frame is wrapped in a <section
+// data-frame-url="..."> that starts with a link to the frame, and its links
+// are made absolute against the frame's URL. Frames are only inlined if
+// frameAllowed accepts their URL; the others, and out-of-process frames
+// Chrome doesn't expose, are left as empty <iframe> elements.
+func flattenedHTML(selector string, frameAllowed func(pageURL, frameURL string) bool, logger *slog.Logger, res *string) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		root, err := dom.GetDocument().WithDepth(-1).WithPierce(true).Do(ctx)
+		if err != nil {
+			return fmt.Errorf("getting document: %w", err)
+		}
+		id, err := dom.QuerySelector(root.NodeID, selector).Do(ctx)
+		if err != nil {
+			return fmt.Errorf("finding %q: %w", selector, err)
+		}
+		node := findNodeByID(root, id)
+		if node == nil {
+			return fmt.Errorf("selector %q not found", selector)
+		}
+
+		f := &frameFlattener{pageURL: root.DocumentURL, allowed: frameAllowed, logger: logger}
+		var out strings.Builder
+		f.write(&out, node, nil, nil, false)
+		*res = out.String()
+		return nil
+	})
+}
+
+func findNodeByID(n *cdp.Node, id cdp.NodeID) *cdp.Node {
+	if n.NodeID == id {
+		return n
+	}
+	for _, c := range n.Children {
+		if found := findNodeByID(c, id); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// slotScope is the shadow host whose light DOM children fill the <slot>
+// elements of its shadow tree.
+type slotScope struct {
+	host   *cdp.Node
+	parent *slotScope
+}
+
+type frameFlattener struct {
+	pageURL string
+	allowed func(pageURL, frameURL string) bool
+	logger  *slog.Logger
+}
+
+// write serializes n. base is set inside frames, to make links absolute;
+// the main document's links already are.
+func (f *frameFlattener) write(out *strings.Builder, n *cdp.Node, slots *slotScope, base *url.URL, raw bool) {
+	switch n.NodeType {
+	case cdp.NodeTypeText:
+		if raw {
+			out.WriteString(n.NodeValue)
+		} else {
+			out.WriteString(html.EscapeString(n.NodeValue))
+		}
+		return
+	case cdp.NodeTypeDocument, cdp.NodeTypeDocumentFragment:
+		for _, c := range n.Children {
+			f.write(out, c, slots, base, false)
+		}
+		return
+	case cdp.NodeTypeElement:
+	default:
+		// comments, doctypes, processing instructions
+		return
+	}
+
+	name := strings.ToLower(n.LocalName)
+
+	// slots are replaced with the light DOM nodes assigned to them, or
+	// their fallback content if there are none
+	if name == "slot" && slots != nil {
+		if assigned := slotted(slots.host, n.AttributeValue("name")); len(assigned) > 0 {
+			for _, c := range assigned {
+				f.write(out, c, slots.parent, base, false)
+			}
+			return
+		}
+		for _, c := range n.Children {
+			f.write(out, c, slots, base, false)
+		}
+		return
+	}
+
+	if (name == "iframe" || name == "frame") && n.ContentDocument != nil {
+		if f.writeFrame(out, n.ContentDocument) {
+			return
+		}
+	}
+
+	out.WriteString("<" + name)
+	for i := 0; i+1 < len(n.Attributes); i += 2 {
+		key, val := n.Attributes[i], n.Attributes[i+1]
+		if base != nil && ((name == "a" && key == "href") || (name == "img" && key == "src")) {
+			if u, err := base.Parse(strings.TrimSpace(val)); err == nil {
+				val = u.String()
+			}
+		}
+		fmt.Fprintf(out, ` %s="%s"`, key, html.EscapeString(val))
+	}
+	out.WriteString(">")
+	if voidElements[name] {
+		return
+	}
+
+	// template content isn't rendered
+	if name != "template" {
+		var shadow *cdp.Node
+		for _, s := range n.ShadowRoots {
+			if s.ShadowRootType == cdp.ShadowRootTypeOpen {
+				shadow = s
+				break
+			}
+		}
+		if shadow != nil {
+			for _, c := range shadow.Children {
+				f.write(out, c, &slotScope{host: n, parent: slots}, base, false)
+			}
+		} else {
+			for _, c := range n.Children {
+				f.write(out, c, slots, base, rawTextElements[name])
+			}
+		}
+	}
+	out.WriteString("</" + name + ">")
+}
+
+// writeFrame inlines the body of a frame's document. It returns false if the
+// frame isn't allowed.
+func (f *frameFlattener) writeFrame(out *strings.Builder, doc *cdp.Node) bool {
+	frameURL := doc.DocumentURL
+	if !f.allowed(f.pageURL, frameURL) {
+		f.logger.Debug("Not inlining frame", "url", frameURL)
+		return false
+	}
+
+	base, _ := url.Parse(doc.BaseURL)
+	if base == nil {
+		base, _ = url.Parse(frameURL)
+	}
+
+	// only the body is inlined; the frame's head is of no use here
+	content := findElement(doc, "body")
+	if content == nil {
+		content = doc
+	}
+
+	fmt.Fprintf(out, `<section data-frame-url="%s"><p><em>Frame: <a href="%s">%s</a></em></p>`,
+		html.EscapeString(frameURL), html.EscapeString(frameURL), html.EscapeString(frameURL))
+	for _, c := range content.Children {
+		f.write(out, c, nil, base, false)
+	}
+	out.WriteString("</section>")
+	return true
+}
+
+// slotted returns the light DOM children of host assigned to the slot named
+// name ("" for the default slot).
+func slotted(host *cdp.Node, name string) []*cdp.Node {
+	var out []*cdp.Node
+	for _, c := range host.Children {
+		switch c.NodeType {
+		case cdp.NodeTypeElement:
+			if c.AttributeValue("slot") == name {
+				out = append(out, c)
+			}
+		case cdp.NodeTypeText:
+			if name == "" && strings.TrimSpace(c.NodeValue) != "" {
+				out = append(out, c)
+			}
+		}
+	}
+	return out
+}
+
+func findElement(n *cdp.Node, name string) *cdp.Node {
+	if n.NodeType == cdp.NodeTypeElement && strings.EqualFold(n.LocalName, name) {
+		return n
+	}
+	for _, c := range n.Children {
+		if found := findElement(c, name); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// frameAllowed reports whether a frame at frameURL on a page at pageURL may
+// be inlined: same-origin frames (and about:blank/srcdoc frames, which
+// inherit the page's origin) unless the URL policy denies them, and
+// cross-origin frames only if a policy rule or allow glob allows them
+// explicitly. Frames of require_auth rules, or that the caller's policy
+// doesn't allow, are never inlined.
+func (w *WebFetcher) frameAllowed(ctx context.Context, pageURL, frameURL string) bool {
+	if strings.HasPrefix(frameURL, "about:") {
+		return true
+	}
+	d := w.policy.Evaluate(frameURL)
+	if d.Action != PolicyAllow {
+		return false
+	}
+	if p := callerPolicy(ctx); p != nil && p.Evaluate(frameURL).Action != PolicyAllow {
+		return false
+	}
+	page, err := url.Parse(pageURL)
+	if err != nil {
+		return false
+	}
+	frame, err := url.Parse(frameURL)
+	if err != nil {
+		return false
+	}
+	if page.Scheme == frame.Scheme && page.Host == frame.Host {
+		return true
+	}
+	return !d.Default
+}
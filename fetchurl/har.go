@@ -0,0 +1,521 @@
+package fetchurl
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// DefaultHARBodyLimit is the largest response body kept in a HAR file.
+const DefaultHARBodyLimit = 256 * 1024
+
+// HAR is an HTTP Archive (HAR 1.2) of a page's network activity.
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Pages   []HARPage  `json:"pages"`
+	Entries []HAREntry `json:"entries"`
+}
+
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type HARPage struct {
+	StartedDateTime string         `json:"startedDateTime"`
+	ID              string         `json:"id"`
+	Title           string         `json:"title"`
+	PageTimings     HARPageTimings `json:"pageTimings"`
+}
+
+type HARPageTimings struct {
+	OnContentLoad float64 `json:"onContentLoad"`
+	OnLoad        float64 `json:"onLoad"`
+}
+
+type HAREntry struct {
+	PageRef         string      `json:"pageref,omitempty"`
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	ResourceType    string      `json:"_resourceType,omitempty"`
+	Error           string      `json:"_error,omitempty"`
+}
+
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type HARContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARTimings are in milliseconds; -1 means the phase doesn't apply.
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	SSL     float64 `json:"ssl"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// HARRecorder collects the network activity of the browser tab a page is
+// fetched in. Pass one in FetchOptions.HAR; it is filled in even if the fetch
+// fails, so it can be used to see what went wrong. Page fetches served from
+// the cache or without a browser aren't recorded.
+type HARRecorder struct {
+	// BodyLimit is the largest response body kept, in bytes (0 =
+	// DefaultHARBodyLimit, negative = no bodies).
+	BodyLimit int
+	// Redact hides the values of credential headers (Authorization, Cookie,
+	// Set-Cookie, Proxy-Authorization) and of the headers set by the fetch
+	// profile, for HARs handed to someone other than the operator.
+	Redact bool
+
+	mu      sync.Mutex
+	secret  map[string]bool // lower-case names of the profile's headers
+	pending sync.WaitGroup
+	closing bool // set by wait: no more bodies are fetched
+	pageURL string
+	started time.Time // wall time of the first request
+	base    time.Time // monotonic time of the first request
+	onDOM   time.Time
+	onLoad  time.Time
+	order   []network.RequestID
+	entries map[network.RequestID]*harEntry
+	done    []*harEntry // redirected requests, which reuse their request ID
+}
+
+type harEntry struct {
+	HAREntry
+	start time.Time // monotonic
+	end   time.Time
+	resp  *network.Response
+}
+
+// NewHARRecorder returns a recorder that keeps response bodies up to
+// bodyLimit bytes.
+func NewHARRecorder(bodyLimit int) *HARRecorder {
+	return &HARRecorder{BodyLimit: bodyLimit}
+}
+
+// reset drops anything recorded by an earlier attempt.
+func (r *HARRecorder) reset(pageURL string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pageURL = pageURL
+	r.started, r.base, r.onDOM, r.onLoad = time.Time{}, time.Time{}, time.Time{}, time.Time{}
+	r.order = nil
+	r.entries = map[network.RequestID]*harEntry{}
+	r.done = nil
+	r.secret = map[string]bool{}
+	r.closing = false
+}
+
+// setup starts recording the tab's network events. It must run before
+// navigating. A nil recorder is a no-op.
+func (r *HARRecorder) setup(pageURL string, profile *FetchProfile) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if r == nil {
+			return nil
+		}
+		r.reset(pageURL)
+		if profile != nil {
+			r.mu.Lock()
+			for name := range profile.Headers {
+				r.secret[strings.ToLower(name)] = true
+			}
+			r.mu.Unlock()
+		}
+
+		c := chromedp.FromContext(ctx)
+		execCtx := cdp.WithExecutor(ctx, c.Target)
+		chromedp.ListenTarget(ctx, func(ev any) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			switch ev := ev.(type) {
+			case *network.EventRequestWillBeSent:
+				r.requestWillBeSent(ev)
+			case *network.EventResponseReceived:
+				if e := r.entries[ev.RequestID]; e != nil {
+					e.resp = ev.Response
+					e.ResourceType = strings.ToLower(ev.Type.String())
+				}
+			case *network.EventLoadingFinished:
+				e := r.entries[ev.RequestID]
+				if e == nil {
+					return
+				}
+				e.end = monotonic(ev.Timestamp)
+				e.Response.BodySize = int(ev.EncodedDataLength)
+				if e.resp != nil && r.BodyLimit >= 0 && !r.closing {
+					// bodies are fetched off the event loop
+					r.pending.Add(1)
+					go r.fetchBody(execCtx, ev.RequestID, e)
+				}
+			case *network.EventLoadingFailed:
+				if e := r.entries[ev.RequestID]; e != nil {
+					e.end = monotonic(ev.Timestamp)
+					e.Error = ev.ErrorText
+					if ev.BlockedReason != "" {
+						e.Error += " (" + ev.BlockedReason.String() + ")"
+					}
+				}
+			case *page.EventDomContentEventFired:
+				r.onDOM = monotonic(ev.Timestamp)
+			case *page.EventLoadEventFired:
+				r.onLoad = monotonic(ev.Timestamp)
+			}
+		})
+		return nil
+	})
+}
+
+// wait waits for response bodies that are still being fetched. It must be
+// called before the tab is closed. Requests that finish after it is called
+// are kept without their bodies.
+func (r *HARRecorder) wait() {
+	if r == nil {
+		return
+	}
+	// no Add may race with the Wait, so stop starting fetches first
+	r.mu.Lock()
+	r.closing = true
+	r.mu.Unlock()
+	r.pending.Wait()
+}
+
+func (r *HARRecorder) requestWillBeSent(ev *network.EventRequestWillBeSent) {
+	start := monotonic(ev.Timestamp)
+	if r.base.IsZero() {
+		r.base = start
+		if ev.WallTime != nil {
+			r.started = time.Time(*ev.WallTime)
+		} else {
+			r.started = time.Now()
+		}
+	}
+
+	// a redirect reuses the request ID, so the previous hop is done
+	if prev := r.entries[ev.RequestID]; prev != nil && ev.RedirectResponse != nil {
+		prev.resp = ev.RedirectResponse
+		prev.end = start
+		r.done = append(r.done, prev)
+	} else if prev == nil {
+		r.order = append(r.order, ev.RequestID)
+	}
+
+	req := ev.Request
+	e := &harEntry{start: start}
+	e.PageRef = "page_1"
+	e.StartedDateTime = r.started.Add(start.Sub(r.base)).Format(time.RFC3339Nano)
+	e.ResourceType = strings.ToLower(ev.Type.String())
+	e.Request = HARRequest{
+		Method:      req.Method,
+		URL:         req.URL + req.URLFragment,
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []HARNameValue{},
+		Headers:     harHeaders(req.Headers),
+		QueryString: harQueryString(req.URL),
+		HeadersSize: -1,
+		BodySize:    0,
+	}
+	if req.HasPostData {
+		e.Request.BodySize = -1
+	}
+	r.entries[ev.RequestID] = e
+}
+
+func (r *HARRecorder) fetchBody(ctx context.Context, id network.RequestID, e *harEntry) {
+	defer r.pending.Done()
+
+	limit := r.BodyLimit
+	if limit == 0 {
+		limit = DefaultHARBodyLimit
+	}
+	body, err := network.GetResponseBody(id).Do(ctx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch {
+	case err != nil:
+		e.Response.Content.Comment = "body not available"
+	case len(body) > limit:
+		e.Response.Content.Size = len(body)
+		e.Response.Content.Comment = fmt.Sprintf("body not kept, over the %d bytes limit", limit)
+	default:
+		e.Response.Content.Size = len(body)
+		if utf8.Valid(body) {
+			e.Response.Content.Text = string(body)
+		} else {
+			e.Response.Content.Text = base64.StdEncoding.EncodeToString(body)
+			e.Response.Content.Encoding = "base64"
+		}
+	}
+}
+
+// HAR returns what has been recorded so far.
+func (r *HARRecorder) HAR() *HAR {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	har := &HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "mcpfurl", Version: buildVersion()},
+		Pages:   []HARPage{},
+		Entries: []HAREntry{},
+	}}
+	if r.base.IsZero() {
+		return har
+	}
+
+	har.Log.Pages = append(har.Log.Pages, HARPage{
+		StartedDateTime: r.started.Format(time.RFC3339Nano),
+		ID:              "page_1",
+		Title:           r.pageURL,
+		PageTimings: HARPageTimings{
+			OnContentLoad: r.sinceStart(r.onDOM),
+			OnLoad:        r.sinceStart(r.onLoad),
+		},
+	})
+
+	all := append([]*harEntry(nil), r.done...)
+	for _, id := range r.order {
+		all = append(all, r.entries[id])
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].start.Before(all[j].start) })
+	for _, e := range all {
+		entry := e.finish()
+		if r.Redact {
+			r.redact(entry.Request.Headers)
+			r.redact(entry.Response.Headers)
+		}
+		har.Log.Entries = append(har.Log.Entries, entry)
+	}
+	return har
+}
+
+// credentialHeaders are redacted from HARs with Redact set.
+var credentialHeaders = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"set-cookie":          true,
+}
+
+// redact replaces the values of credential and profile headers. r.mu must be
+// held.
+func (r *HARRecorder) redact(headers []HARNameValue) {
+	for i, h := range headers {
+		name := strings.ToLower(h.Name)
+		if credentialHeaders[name] || r.secret[name] {
+			headers[i].Value = "[redacted]"
+		}
+	}
+}
+
+// WriteFile writes the HAR recorded so far to path as JSON.
+func (r *HARRecorder) WriteFile(path string) error {
+	data, err := json.MarshalIndent(r.HAR(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// sinceStart returns the milliseconds from the first request to t, or -1.
+func (r *HARRecorder) sinceStart(t time.Time) float64 {
+	if t.IsZero() {
+		return -1
+	}
+	return millis(t.Sub(r.base))
+}
+
+// finish fills in the response and timings of an entry.
+func (e *harEntry) finish() HAREntry {
+	out := e.HAREntry
+	out.Timings = HARTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1, Send: 0, Wait: 0, Receive: 0}
+	out.Response.Cookies = []HARNameValue{}
+	out.Response.Headers = []HARNameValue{}
+	out.Response.HeadersSize = -1
+
+	resp := e.resp
+	if resp == nil {
+		// failed or still in flight
+		out.Response.HTTPVersion = out.Request.HTTPVersion
+		out.Response.BodySize = -1
+		if !e.end.IsZero() {
+			out.Time = millis(e.end.Sub(e.start))
+		}
+		return out
+	}
+
+	out.Response.Status = int(resp.Status)
+	out.Response.StatusText = resp.StatusText
+	out.Response.HTTPVersion = harHTTPVersion(resp.Protocol)
+	out.Request.HTTPVersion = out.Response.HTTPVersion
+	out.Response.Headers = harHeaders(resp.Headers)
+	out.Response.Content.MimeType = resp.MimeType
+	if resp.Charset != "" {
+		out.Response.Content.MimeType += "; charset=" + resp.Charset
+	}
+	if loc, ok := resp.Headers["Location"]; ok {
+		out.Response.RedirectURL = fmt.Sprint(loc)
+	} else if loc, ok := resp.Headers["location"]; ok {
+		out.Response.RedirectURL = fmt.Sprint(loc)
+	}
+	if resp.FromDiskCache {
+		out.Response.BodySize = 0
+	}
+	out.ServerIPAddress = resp.RemoteIPAddress
+	if len(resp.RequestHeaders) > 0 {
+		out.Request.Headers = harHeaders(resp.RequestHeaders)
+	}
+
+	if t := resp.Timing; t != nil {
+		phase := func(start, end float64) float64 {
+			if start < 0 || end < 0 {
+				return -1
+			}
+			return end - start
+		}
+		// the first timestamp CDP reports is where the request left the
+		// queue; anything before that was blocked
+		first := t.SendStart
+		for _, v := range []float64{t.DNSStart, t.ConnectStart, t.ProxyStart} {
+			if v >= 0 && v < first {
+				first = v
+			}
+		}
+		out.Timings.Blocked = first
+		out.Timings.DNS = phase(t.DNSStart, t.DNSEnd)
+		out.Timings.Connect = phase(t.ConnectStart, t.ConnectEnd)
+		out.Timings.SSL = phase(t.SslStart, t.SslEnd)
+		out.Timings.Send = max(phase(t.SendStart, t.SendEnd), 0)
+		out.Timings.Wait = max(t.ReceiveHeadersEnd-t.SendEnd, 0)
+		if !e.end.IsZero() {
+			headersDone := cdp.MonotonicTimeEpoch.Add(time.Duration((t.RequestTime*1000 + t.ReceiveHeadersEnd) * float64(time.Millisecond)))
+			out.Timings.Receive = max(millis(e.end.Sub(headersDone)), 0)
+		}
+	}
+
+	// ssl is part of connect, so it isn't added again
+	out.Time = 0
+	for _, v := range []float64{out.Timings.Blocked, out.Timings.DNS, out.Timings.Connect, out.Timings.Send, out.Timings.Wait, out.Timings.Receive} {
+		if v > 0 {
+			out.Time += v
+		}
+	}
+	return out
+}
+
+// buildVersion returns the module version mcpfurl was built from.
+func buildVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+func monotonic(t *cdp.MonotonicTime) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return time.Time(*t)
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func harHTTPVersion(protocol string) string {
+	switch strings.ToLower(protocol) {
+	case "h2":
+		return "HTTP/2"
+	case "h3", "h3-29":
+		return "HTTP/3"
+	case "http/1.0":
+		return "HTTP/1.0"
+	}
+	return "HTTP/1.1"
+}
+
+func harHeaders(headers network.Headers) []HARNameValue {
+	out := []HARNameValue{}
+	for name, value := range headers {
+		// repeated headers are joined with newlines
+		for _, v := range strings.Split(fmt.Sprint(value), "\n") {
+			out = append(out, HARNameValue{Name: name, Value: v})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func harQueryString(rawURL string) []HARNameValue {
+	out := []HARNameValue{}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return out
+	}
+	for name, values := range u.Query() {
+		for _, v := range values {
+			out = append(out, HARNameValue{Name: name, Value: v})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
@@ -0,0 +1,323 @@
+package fetchurl
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	FetchModeBrowser = "browser" // always render pages in headless Chrome (default)
+	FetchModeHTTP    = "http"    // plain HTTP GET, no browser (Chrome isn't started)
+	FetchModeAuto    = "auto"    // plain HTTP first, Chrome if the page looks JS-rendered
+
+	// minBodyTextLen is the amount of visible body text below which a page
+	// is assumed to be rendered client-side.
+	minBodyTextLen = 200
+)
+
+// ValidFetchMode reports whether mode is one of the FetchMode constants.
+func ValidFetchMode(mode string) bool {
+	switch mode {
+	case FetchModeBrowser, FetchModeHTTP, FetchModeAuto:
+		return true
+	}
+	return false
+}
+
+// fetchURLHTTP fetches targetURL with a plain HTTP GET and returns the element
+// matching selector, minus any excluded elements, with relative a-href/img-src
+// links made absolute. Any matching FetchProfile headers, cookies, user agent
+// and language are sent with the request. JSON, text, XML and PDF documents
+// are converted by newDocumentPage instead.
+//
+// If the response doesn't look usable without a browser, needsBrowser says
+// why. The page is still returned when there is one (a JS app shell, say), so
+// FetchModeHTTP can use it while FetchModeAuto falls back to Chrome.
+func (w *WebFetcher) fetchURLHTTP(ctx context.Context, targetURL string, selector string, exclude []string, profile *FetchProfile) (page *FetchedWebPage, needsBrowser string, err error) {
+	// the same limit as a page load in the browser, body included
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(w.opts.PageLoadTimeoutSecs)*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, targetURL, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("error building request: %w", err)
+	}
+	req.Header.Set("User-Agent", defaultUserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.8")
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+	if profile != nil {
+		if profile.UserAgent != "" {
+			req.Header.Set("User-Agent", profile.UserAgent)
+		} else if profile.Device != "" {
+			if dev, err := LookupDevice(profile.Device); err == nil {
+				req.Header.Set("User-Agent", dev.UserAgent)
+			}
+		}
+		if profile.AcceptLanguage != "" {
+			req.Header.Set("Accept-Language", profile.AcceptLanguage)
+		}
+	}
+	// what redirects to URLs the profile doesn't match get instead of its
+	// headers
+	baseHeader := req.Header.Clone()
+	if profile != nil {
+		for k, v := range profile.Headers {
+			req.Header.Set(k, v)
+		}
+		for _, c := range profile.cookiesFor(req.URL, req.URL) {
+			req.AddCookie(c)
+		}
+	}
+
+	var redirects []RedirectHop
+	client := w.httpClient()
+	client.CheckRedirect = func(next *http.Request, via []*http.Request) error {
+		if err := w.checkRedirect(next, via); err != nil {
+			return err
+		}
+		if profile != nil {
+			// the profile's headers and cookies only go where a browser
+			// would send them
+			if !profile.matches(next.URL.String()) {
+				for k := range profile.Headers {
+					if v := baseHeader.Values(k); len(v) > 0 {
+						next.Header[http.CanonicalHeaderKey(k)] = v
+					} else {
+						next.Header.Del(k)
+					}
+				}
+			}
+			next.Header.Del("Cookie")
+			for _, c := range profile.cookiesFor(req.URL, next.URL) {
+				next.AddCookie(c)
+			}
+		}
+		redirects = append(redirects, RedirectHop{
+			URL:        next.Response.Request.URL.String(),
+			StatusCode: next.Response.StatusCode,
+			Location:   next.URL.String(),
+		})
+		return nil
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("error fetching %s: %w", targetURL, err)
+	}
+	defer resp.Body.Close()
+
+	// error pages are returned like any other page, and FetchURLWithOptions
+	// decides what to do with them. Responses that can't be turned into a
+	// page are an error here, so FetchModeAuto doesn't retry them.
+	isError := resp.StatusCode >= 400
+	if resp.StatusCode < 200 || (resp.StatusCode >= 300 && !isError) {
+		return nil, "", fmt.Errorf("unexpected status code %d fetching %s", resp.StatusCode, targetURL)
+	}
+
+	mediaType := mediaTypeOf(resp.Header.Get("Content-Type"))
+	if !isHTMLType(mediaType) && (isError || documentKind(mediaType) == "") {
+		if isError {
+			return nil, "", &HTTPStatusError{URL: targetURL, StatusCode: resp.StatusCode}
+		}
+		return nil, "", fmt.Errorf("unsupported content type %q", mediaType)
+	}
+
+	limit := w.opts.MaxDownloadBytes
+	if limit <= 0 {
+		limit = DefaultMaxDownloadBytes
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, int64(limit)+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("error reading response body: %w", err)
+	}
+	if len(body) > limit {
+		return nil, "", fmt.Errorf("page exceeds %d bytes limit", limit)
+	}
+
+	currentURL := resp.Request.URL
+
+	// JSON, text, XML and PDF documents don't need a browser
+	if !isHTMLType(mediaType) {
+		page, err := newDocumentPage(targetURL, currentURL.String(), mediaType, body)
+		if err != nil {
+			return nil, "", err
+		}
+		page.StatusCode = resp.StatusCode
+		page.Headers = pickHeaders(resp.Header.Get)
+		page.Redirects = redirects
+		return page, "", nil
+	}
+
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, "", fmt.Errorf("error parsing HTML from %s: %w", targetURL, err)
+	}
+	needsBrowser = looksJSRendered(doc)
+
+	absolutizeLinks(doc, baseURL(doc, currentURL))
+
+	sel, err := cascadia.Compile(selector)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid selector %q: %w", selector, err)
+	}
+	node := cascadia.Query(doc, sel)
+	if node == nil {
+		if isError {
+			return nil, "", &HTTPStatusError{URL: targetURL, StatusCode: resp.StatusCode}
+		}
+		return nil, fmt.Sprintf("selector %q not found in HTML", selector), nil
+	}
+
+	if err := removeMatching(node, exclude); err != nil {
+		return nil, "", err
+	}
+
+	var src strings.Builder
+	if err := html.Render(&src, node); err != nil {
+		return nil, "", fmt.Errorf("error rendering HTML: %w", err)
+	}
+
+	return &FetchedWebPage{
+		TargetURL:  targetURL,
+		CurrentURL: currentURL.String(),
+		Title:      strings.TrimSpace(nodeText(findFirst(doc, atom.Title))),
+		Src:        src.String(),
+		StatusCode: resp.StatusCode,
+		Headers:    pickHeaders(resp.Header.Get),
+		Redirects:  redirects,
+	}, needsBrowser, nil
+}
+
+// looksJSRendered returns a reason if the page appears to need JavaScript to
+// render its content: a near-empty body or a <noscript> notice asking for
+// JavaScript. It returns "" for pages that look usable as is.
+func looksJSRendered(doc *html.Node) string {
+	body := findFirst(doc, atom.Body)
+	if body == nil {
+		return "page has no body"
+	}
+
+	for _, n := range findAll(body, atom.Noscript) {
+		// noscript content is parsed as raw text, so this includes any markup
+		text := ""
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			text += strings.ToLower(nodeText(c))
+		}
+		if strings.Contains(text, "javascript") && (strings.Contains(text, "enable") || strings.Contains(text, "require") || strings.Contains(text, "need")) {
+			return "page has a <noscript> JavaScript notice"
+		}
+	}
+
+	if n := len(strings.Join(strings.Fields(nodeText(body)), " ")); n < minBodyTextLen {
+		return fmt.Sprintf("page body only has %d characters of text", n)
+	}
+	return ""
+}
+
+// baseURL returns the URL relative links resolve against, honouring
+// <base href>.
+func baseURL(doc *html.Node, pageURL *url.URL) *url.URL {
+	if base := findFirst(doc, atom.Base); base != nil {
+		if href := attr(base, "href"); href != "" {
+			if u, err := pageURL.Parse(href); err == nil {
+				return u
+			}
+		}
+	}
+	return pageURL
+}
+
+// absolutizeLinks rewrites a-href and img-src attributes to absolute URLs,
+// the same as the browser path does with JavaScript.
+func absolutizeLinks(doc *html.Node, base *url.URL) {
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			key := ""
+			switch n.DataAtom {
+			case atom.A:
+				key = "href"
+			case atom.Img:
+				key = "src"
+			}
+			if key != "" {
+				for i, a := range n.Attr {
+					if a.Namespace == "" && a.Key == key {
+						if u, err := base.Parse(strings.TrimSpace(a.Val)); err == nil {
+							n.Attr[i].Val = u.String()
+						}
+					}
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+}
+
+func findFirst(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findFirst(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func findAll(n *html.Node, a atom.Atom) []*html.Node {
+	var out []*html.Node
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		out = append(out, n)
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		out = append(out, findAll(c, a)...)
+	}
+	return out
+}
+
+// nodeText returns the text content of n, skipping script, style and
+// noscript elements.
+func nodeText(n *html.Node) string {
+	if n == nil {
+		return ""
+	}
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			b.WriteString(n.Data)
+		case n.Type == html.ElementNode && (n.DataAtom == atom.Script || n.DataAtom == atom.Style || n.DataAtom == atom.Noscript):
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
@@ -23,10 +23,14 @@ func (w *WebFetcher) DownloadResource(ctx context.Context, targetURL string) (*D
 		return nil, fmt.Errorf("missing URL")
 	}
 
-	// check allow/disallow lists first
-	if allowed, err := ensureURLAllowed(targetURL, w.opts.AllowedURLGlobs, w.opts.DenyURLGlobs); err != nil {
+	// check the URL policy first
+	if err := w.checkPolicy(ctx, targetURL); err != nil {
 		return nil, err
-	} else if !allowed {
+	}
+	if err := w.checkRobots(ctx, targetURL, false); err != nil {
+		return nil, err
+	}
+	if err := w.checkAddress(ctx, targetURL); err != nil {
 		return nil, err
 	}
 
@@ -35,7 +39,7 @@ func (w *WebFetcher) DownloadResource(ctx context.Context, targetURL string) (*D
 		return nil, fmt.Errorf("error building request: %w", err)
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := w.httpClient().Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("error downloading %s: %w", targetURL, err)
 	}
@@ -0,0 +1,251 @@
+package fetchurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// reservedNetworks are the address ranges a public server shouldn't reach on
+// behalf of its callers: loopback, private, link-local (including cloud
+// metadata endpoints), CGNAT, IPv6 unique local, multicast and the like.
+var reservedNetworks = mustParseCIDRs(
+	"0.0.0.0/8",      // "this" network
+	"10.0.0.0/8",     // private
+	"100.64.0.0/10",  // carrier-grade NAT
+	"127.0.0.0/8",    // loopback
+	"169.254.0.0/16", // link-local, cloud metadata (169.254.169.254)
+	"172.16.0.0/12",  // private
+	"192.0.0.0/24",   // IETF protocol assignments
+	"192.168.0.0/16", // private
+	"198.18.0.0/15",  // benchmarking
+	"224.0.0.0/4",    // multicast
+	"240.0.0.0/4",    // reserved, broadcast
+	"::/128",         // unspecified
+	"::1/128",        // loopback
+	"64:ff9b::/96",   // NAT64, which can reach any IPv4 address
+	"fc00::/7",       // unique local
+	"fe80::/10",      // link-local
+	"ff00::/8",       // multicast
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	out := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		out = append(out, n)
+	}
+	return out
+}
+
+// BlockedAddressError is returned when a URL's host is, or resolves to, a
+// private or reserved address.
+type BlockedAddressError struct {
+	Host string
+	IP   net.IP
+}
+
+func (e *BlockedAddressError) Error() string {
+	if e.IP.String() == e.Host {
+		return fmt.Sprintf("address %s is private or reserved and can't be fetched", e.Host)
+	}
+	return fmt.Sprintf("host %q resolves to %s, a private or reserved address that can't be fetched", e.Host, e.IP)
+}
+
+// ipGuard keeps fetches from reaching private and reserved addresses, other
+// than the explicitly allowed networks.
+type ipGuard struct {
+	allow    []*net.IPNet
+	resolver *net.Resolver
+	// proxies are the host:port of the configured proxies, which may be on
+	// the local network. Requests through them are checked by their URL.
+	proxies map[string]bool
+}
+
+// newIPGuard parses the allowed networks (CIDRs, or single addresses). It
+// returns nil if enabled is false.
+func newIPGuard(enabled bool, allowed []string, proxy *proxyRouter) (*ipGuard, error) {
+	if !enabled {
+		return nil, nil
+	}
+	g := &ipGuard{resolver: net.DefaultResolver, proxies: map[string]bool{}}
+	for _, a := range allowed {
+		a = strings.TrimSpace(a)
+		if a == "" {
+			continue
+		}
+		if !strings.Contains(a, "/") {
+			if ip := net.ParseIP(a); ip != nil {
+				bits := 128
+				if ip.To4() != nil {
+					bits = 32
+				}
+				a = fmt.Sprintf("%s/%d", a, bits)
+			}
+		}
+		_, n, err := net.ParseCIDR(a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed network %q (expected a CIDR such as 10.1.0.0/16)", a)
+		}
+		g.allow = append(g.allow, n)
+	}
+	if proxy != nil {
+		for _, u := range proxy.proxies() {
+			g.proxies[strings.ToLower(proxyHostPort(u))] = true
+		}
+	}
+	// without a configured proxy, the Go clients use the environment's
+	for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"} {
+		if u, err := url.Parse(os.Getenv(name)); err == nil && u.Host != "" {
+			g.proxies[strings.ToLower(proxyHostPort(u))] = true
+		}
+	}
+	return g, nil
+}
+
+// blocked reports whether ip is private or reserved and not allowed.
+func (g *ipGuard) blocked(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		// IPv4-mapped IPv6 addresses are checked as IPv4
+		ip = ip4
+	}
+	for _, n := range g.allow {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	for _, n := range reservedNetworks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve returns the addresses of host, or a BlockedAddressError if any of
+// them is blocked.
+func (g *ipGuard) resolve(ctx context.Context, host string) ([]net.IP, error) {
+	host = strings.TrimSuffix(strings.Trim(host, "[]"), ".")
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		addrs, err := g.resolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+	}
+	for _, ip := range ips {
+		if g.blocked(ip) {
+			return nil, &BlockedAddressError{Host: host, IP: ip}
+		}
+	}
+	return ips, nil
+}
+
+// checkURL returns a BlockedAddressError if the URL's host is, or resolves
+// to, a blocked address. A nil guard allows everything.
+func (g *ipGuard) checkURL(ctx context.Context, rawURL string) error {
+	if g == nil {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil
+	}
+	_, err = g.resolve(ctx, u.Hostname())
+	return err
+}
+
+// checkAddress returns a BlockedAddressError if targetURL's host is, or
+// resolves to, a blocked address, before a fetch starts. Lookup errors are
+// left to the fetch itself (a proxy may resolve names this host can't).
+func (w *WebFetcher) checkAddress(ctx context.Context, targetURL string) error {
+	var addrErr *BlockedAddressError
+	if err := w.guard.checkURL(ctx, targetURL); errors.As(err, &addrErr) {
+		return addrErr
+	}
+	return nil
+}
+
+// dialContext checks the addresses of the host being dialed and connects to
+// one that was checked, so the name can't be re-resolved to another address
+// in between (DNS rebinding). Connections to the configured proxies aren't
+// checked; proxyFunc checks the requested URL instead.
+func (g *ipGuard) dialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if g.proxies[strings.ToLower(addr)] {
+			return dialer.DialContext(ctx, network, addr)
+		}
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		ips, err := g.resolve(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		var firstErr error
+		for _, ip := range ips {
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		return nil, firstErr
+	}
+}
+
+// proxyFunc wraps a transport's Proxy function so that requests sent through
+// a proxy are checked by their URL, since the guarded dialer only sees the
+// proxy's address.
+func (g *ipGuard) proxyFunc(next func(*http.Request) (*url.URL, error)) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		proxyURL, err := next(req)
+		if err != nil || proxyURL == nil {
+			return proxyURL, err
+		}
+		// the proxy may resolve names this host can't, so only addresses
+		// known to be blocked are refused
+		var addrErr *BlockedAddressError
+		if err := g.checkURL(req.Context(), req.URL.String()); errors.As(err, &addrErr) {
+			return nil, addrErr
+		}
+		return proxyURL, nil
+	}
+}
+
+// newTransport returns the http.RoundTripper for requests to fetched URLs,
+// through the proxy and the guard (either may be nil).
+func newTransport(proxy *proxyRouter, guard *ipGuard) http.RoundTripper {
+	if proxy == nil && guard == nil {
+		return http.DefaultTransport
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if proxy != nil {
+		t.Proxy = func(req *http.Request) (*url.URL, error) {
+			return proxy.proxyFor(req.URL), nil
+		}
+	}
+	if guard != nil {
+		t.Proxy = guard.proxyFunc(t.Proxy)
+		// the same settings as http.DefaultTransport's dialer
+		t.DialContext = guard.dialContext(&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second})
+	}
+	return t
+}
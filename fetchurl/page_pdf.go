@@ -0,0 +1,186 @@
+package fetchurl
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// paperSizes are the supported PrintOptions.PaperSize values, width x height
+// in inches.
+var paperSizes = map[string][2]float64{
+	"letter":  {8.5, 11},
+	"legal":   {8.5, 14},
+	"tabloid": {11, 17},
+	"ledger":  {17, 11},
+	"a3":      {11.69, 16.54},
+	"a4":      {8.27, 11.69},
+	"a5":      {5.83, 8.27},
+}
+
+// PrintOptions control how FetchURLPDF prints a page. The zero value prints
+// on letter paper with Chrome's default margins and no background graphics.
+type PrintOptions struct {
+	PaperSize       string // letter (default), legal, tabloid, ledger, a3, a4 or a5
+	Landscape       bool
+	Margins         string // in inches: one value for every side, or "top,right,bottom,left"
+	PrintBackground bool   // print background colors and images
+	HeaderTemplate  string // HTML for the page header (see Page.printToPDF)
+	FooterTemplate  string // HTML for the page footer
+	PageRanges      string // pages to print (ex: "1-5, 8"), empty for all
+}
+
+// Validate checks the paper size and margins.
+func (o PrintOptions) Validate() error {
+	if _, err := o.paperSize(); err != nil {
+		return err
+	}
+	_, err := o.margins()
+	return err
+}
+
+func (o PrintOptions) paperSize() ([2]float64, error) {
+	name := strings.ToLower(strings.TrimSpace(o.PaperSize))
+	if name == "" {
+		name = "letter"
+	}
+	size, ok := paperSizes[name]
+	if !ok {
+		return size, fmt.Errorf("unknown paper size %q (expected letter, legal, tabloid, ledger, a3, a4 or a5)", o.PaperSize)
+	}
+	return size, nil
+}
+
+// margins returns the top, right, bottom and left margins, or nil to use
+// Chrome's defaults.
+func (o PrintOptions) margins() ([]float64, error) {
+	if strings.TrimSpace(o.Margins) == "" {
+		return nil, nil
+	}
+	parts := strings.Split(o.Margins, ",")
+	if len(parts) != 1 && len(parts) != 4 {
+		return nil, fmt.Errorf("invalid margins %q (expected one value or top,right,bottom,left)", o.Margins)
+	}
+	var out []float64
+	for _, p := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("invalid margins %q", o.Margins)
+		}
+		out = append(out, v)
+	}
+	if len(out) == 1 {
+		out = []float64{out[0], out[0], out[0], out[0]}
+	}
+	return out, nil
+}
+
+// params builds the Page.printToPDF call for these options.
+func (o PrintOptions) params() (*page.PrintToPDFParams, error) {
+	size, err := o.paperSize()
+	if err != nil {
+		return nil, err
+	}
+	margins, err := o.margins()
+	if err != nil {
+		return nil, err
+	}
+
+	params := page.PrintToPDF().
+		WithPaperWidth(size[0]).
+		WithPaperHeight(size[1]).
+		WithLandscape(o.Landscape).
+		WithPrintBackground(o.PrintBackground)
+	if margins != nil {
+		params = params.
+			WithMarginTop(margins[0]).
+			WithMarginRight(margins[1]).
+			WithMarginBottom(margins[2]).
+			WithMarginLeft(margins[3])
+	}
+	if o.HeaderTemplate != "" || o.FooterTemplate != "" {
+		// Chrome fills in a missing template with the date/title/URL, so
+		// blank it out instead
+		header, footer := o.HeaderTemplate, o.FooterTemplate
+		if header == "" {
+			header = "<span></span>"
+		}
+		if footer == "" {
+			footer = "<span></span>"
+		}
+		params = params.
+			WithDisplayHeaderFooter(true).
+			WithHeaderTemplate(header).
+			WithFooterTemplate(footer)
+	}
+	if o.PageRanges != "" {
+		params = params.WithPageRanges(o.PageRanges)
+	}
+	return params, nil
+}
+
+// FetchURLPDF renders targetURL in headless Chrome and prints it to PDF. Unlike
+// a screenshot, the PDF keeps the page's text searchable and is split into
+// pages. The matching FetchProfile and readiness wait are applied first.
+func (w *WebFetcher) FetchURLPDF(ctx context.Context, targetURL string, opts PrintOptions) ([]byte, error) {
+
+	// check the URL policy first
+	if err := w.checkPolicy(ctx, targetURL); err != nil {
+		return nil, err
+	}
+	if err := w.checkRobots(ctx, targetURL, false); err != nil {
+		return nil, err
+	}
+	if err := w.checkAddress(ctx, targetURL); err != nil {
+		return nil, err
+	}
+
+	params, err := opts.params()
+	if err != nil {
+		return nil, err
+	}
+
+	profile := w.profileFor(targetURL)
+	wait := w.waitStrategyFor(targetURL)
+	if wait.IsZero() && profile != nil {
+		wait = profile.Wait
+	}
+
+	var buf []byte
+
+	err = w.withTab(ctx, func(tabCtx context.Context) error {
+		tabCtx, cancel := context.WithTimeout(tabCtx, time.Duration(w.opts.PageLoadTimeoutSecs)*time.Second)
+		defer cancel()
+
+		waiter := newReadinessWaiter(wait, DefaultWaitTimeout, w.opts.Logger)
+
+		blocker := w.newRequestBlocker(ctx, ResourceBlocking{}, profile)
+		err := chromedp.Run(tabCtx,
+			stealthSetup(),
+			profile.setup(targetURL),
+			blocker.setup(),
+			waiter.setup(),
+			chromedp.Navigate(targetURL),
+			waiter.wait(),
+			chromedp.ActionFunc(func(ctx context.Context) error {
+				var err error
+				buf, _, err = params.Do(ctx)
+				return err
+			}),
+		)
+		if blockedErr := blocker.err(); blockedErr != nil {
+			return blockedErr
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
@@ -0,0 +1,374 @@
+package fetchurl
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// pdfTruncatedMarker ends markdown cut off at PdfOptions.MaxChars.
+const pdfTruncatedMarker = "\n\n[truncated]\n"
+
+// PdfOptions select what PdfToMarkdown converts.
+type PdfOptions struct {
+	Pages    string // page ranges to convert (ex: "1-3,5,8-"), empty for all pages
+	MaxChars int    // truncate the markdown to this many characters, marker included (0 = no limit)
+}
+
+// PdfDocument is a PDF converted to Markdown.
+type PdfDocument struct {
+	Title     string `json:"title,omitempty"`
+	NumPages  int    `json:"num_pages"`
+	Pages     []int  `json:"pages"` // the pages that were converted
+	Markdown  string `json:"markdown"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// pdfLine is one row of text on a page. Cells are separated by wide gaps,
+// which is how table columns usually end up in the PDF.
+type pdfLine struct {
+	y     float64
+	size  float64
+	cells []string
+}
+
+func (l pdfLine) text() string {
+	return strings.Join(l.cells, " ")
+}
+
+// FetchPDF downloads the PDF at targetURL and converts it to Markdown. If
+// useBrowser is set, the file is downloaded with headless Chrome, for sites
+// that block plain HTTP clients.
+func (w *WebFetcher) FetchPDF(ctx context.Context, targetURL string, useBrowser bool, opts PdfOptions) (*PdfDocument, error) {
+	var resource *DownloadedResource
+	var err error
+	if useBrowser {
+		resource, err = w.BrowserDownloadFile(ctx, targetURL, "")
+	} else {
+		resource, err = w.DownloadResource(ctx, targetURL)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if mediaTypeOf(resource.ContentType) != "application/pdf" && !bytes.HasPrefix(resource.Body, []byte("%PDF-")) {
+		return nil, fmt.Errorf("%s is not a PDF (content type %q)", targetURL, resource.ContentType)
+	}
+
+	doc, err := PdfToMarkdown(resource.Body, opts)
+	if err != nil {
+		return nil, err
+	}
+	if doc.Title == "" {
+		doc.Title = resource.Filename
+	}
+	return doc, nil
+}
+
+// PdfToMarkdown extracts the text of a PDF document as Markdown. Each page
+// starts with a <!-- page N --> marker, lines set in a larger font than the
+// body text become headings, and runs of lines split into columns become
+// tables. Scanned documents without a text layer come back empty.
+func PdfToMarkdown(data []byte, opts PdfOptions) (doc *PdfDocument, err error) {
+	// the PDF parser panics on some malformed documents
+	defer func() {
+		if r := recover(); r != nil {
+			doc, err = nil, fmt.Errorf("error reading PDF: %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("error reading PDF: %w", err)
+	}
+
+	numPages := reader.NumPage()
+	pages, err := parsePageRanges(opts.Pages, numPages)
+	if err != nil {
+		return nil, err
+	}
+
+	doc = &PdfDocument{
+		Title:    strings.TrimSpace(reader.Trailer().Key("Info").Key("Title").Text()),
+		NumPages: numPages,
+		Pages:    pages,
+	}
+
+	lines := make([][]pdfLine, len(pages))
+	for i, num := range pages {
+		page := reader.Page(num)
+		if page.V.IsNull() {
+			continue
+		}
+		lines[i] = pdfPageLines(page.Content().Text)
+	}
+
+	bodySize := pdfBodySize(lines)
+
+	var out strings.Builder
+	for i, num := range pages {
+		fmt.Fprintf(&out, "<!-- page %d -->\n\n", num)
+		writePdfPage(&out, lines[i], bodySize)
+	}
+
+	doc.Markdown = strings.TrimSpace(out.String()) + "\n"
+	if opts.MaxChars > 0 && utf8.RuneCountInString(doc.Markdown) > opts.MaxChars {
+		// the marker counts towards the limit, and is left out if it doesn't fit
+		keep, marker := opts.MaxChars-len(pdfTruncatedMarker), pdfTruncatedMarker
+		if keep <= 0 {
+			keep, marker = opts.MaxChars, ""
+		}
+		doc.Markdown = string([]rune(doc.Markdown)[:keep]) + marker
+		doc.Truncated = true
+	}
+	return doc, nil
+}
+
+// pageRange is an inclusive range of page numbers. end is 0 for open ranges
+// ("8-").
+type pageRange struct {
+	start, end int
+}
+
+// ValidatePageRanges checks the syntax of a list of page ranges such as
+// "1-3,5,8-".
+func ValidatePageRanges(spec string) error {
+	_, err := parsePageRangeSpec(spec)
+	return err
+}
+
+func parsePageRangeSpec(spec string) ([]pageRange, error) {
+	var ranges []pageRange
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		from, to := part, part
+		if i := strings.Index(part, "-"); i >= 0 {
+			from, to = strings.TrimSpace(part[:i]), strings.TrimSpace(part[i+1:])
+			if from == "" {
+				from = "1"
+			}
+		}
+		r := pageRange{}
+		var err error
+		if r.start, err = strconv.Atoi(from); err != nil || r.start < 1 {
+			return nil, fmt.Errorf("invalid page range %q", part)
+		}
+		if to != "" {
+			if r.end, err = strconv.Atoi(to); err != nil || r.end < r.start {
+				return nil, fmt.Errorf("invalid page range %q", part)
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// parsePageRanges expands a list of page ranges into sorted page numbers. An
+// empty spec selects every page.
+func parsePageRanges(spec string, numPages int) ([]int, error) {
+	var pages []int
+	if strings.TrimSpace(spec) == "" {
+		for i := 1; i <= numPages; i++ {
+			pages = append(pages, i)
+		}
+		return pages, nil
+	}
+
+	ranges, err := parsePageRangeSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[int]bool{}
+	for _, r := range ranges {
+		if r.start > numPages {
+			return nil, fmt.Errorf("page %d is past the last page (%d)", r.start, numPages)
+		}
+		end := r.end
+		if end == 0 || end > numPages {
+			end = numPages
+		}
+		for p := r.start; p <= end; p++ {
+			if !seen[p] {
+				seen[p] = true
+				pages = append(pages, p)
+			}
+		}
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("no pages selected")
+	}
+	sort.Ints(pages)
+	return pages, nil
+}
+
+// pdfPageLines groups the glyphs of a page into lines, top to bottom.
+func pdfPageLines(glyphs []pdf.Text) []pdfLine {
+	type row struct {
+		y      float64
+		glyphs []pdf.Text
+	}
+	var rows []*row
+	for _, g := range glyphs {
+		if strings.TrimSpace(g.S) == "" && g.S != " " {
+			continue
+		}
+		var found *row
+		for _, r := range rows {
+			if math.Abs(r.y-g.Y) < max(g.FontSize, 1)*0.4 {
+				found = r
+				break
+			}
+		}
+		if found == nil {
+			found = &row{y: g.Y}
+			rows = append(rows, found)
+		}
+		found.glyphs = append(found.glyphs, g)
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].y > rows[j].y })
+
+	var lines []pdfLine
+	for _, r := range rows {
+		sort.SliceStable(r.glyphs, func(i, j int) bool { return r.glyphs[i].X < r.glyphs[j].X })
+
+		line := pdfLine{y: r.y}
+		var cell strings.Builder
+		var prev *pdf.Text
+		for i := range r.glyphs {
+			g := &r.glyphs[i]
+			line.size = max(line.size, g.FontSize)
+			if prev != nil {
+				gap := g.X - (prev.X + prev.W)
+				size := max(g.FontSize, 1)
+				switch {
+				case gap > size*1.5:
+					line.cells = appendCell(line.cells, cell.String())
+					cell.Reset()
+				case gap > size*0.2 && prev.S != " " && g.S != " ":
+					cell.WriteString(" ")
+				}
+			}
+			cell.WriteString(g.S)
+			prev = g
+		}
+		line.cells = appendCell(line.cells, cell.String())
+		if len(line.cells) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func appendCell(cells []string, cell string) []string {
+	cell = strings.Join(strings.Fields(cell), " ")
+	if cell == "" {
+		return cells
+	}
+	return append(cells, cell)
+}
+
+// pdfBodySize is the most common font size, weighted by the amount of text.
+func pdfBodySize(pages [][]pdfLine) float64 {
+	counts := map[float64]int{}
+	for _, lines := range pages {
+		for _, l := range lines {
+			counts[math.Round(l.size*2)/2] += len(l.text())
+		}
+	}
+	body, best := 0.0, 0
+	for size, n := range counts {
+		if n > best || (n == best && size < body) {
+			body, best = size, n
+		}
+	}
+	return body
+}
+
+// headingLevel returns the Markdown heading level for a line, or 0 for body
+// text.
+func headingLevel(l pdfLine, bodySize float64) int {
+	if bodySize <= 0 || len(l.cells) != 1 || len(l.text()) > 120 {
+		return 0
+	}
+	ratio := l.size / bodySize
+	switch {
+	case ratio >= 1.8:
+		return 1
+	case ratio >= 1.4:
+		return 2
+	case ratio >= 1.15:
+		return 3
+	}
+	return 0
+}
+
+func writePdfPage(out *strings.Builder, lines []pdfLine, bodySize float64) {
+	for i := 0; i < len(lines); {
+		l := lines[i]
+
+		// two or more consecutive multi-cell lines are a table
+		if len(l.cells) > 1 {
+			j := i
+			for j < len(lines) && len(lines[j].cells) > 1 {
+				j++
+			}
+			if j-i > 1 {
+				writePdfTable(out, lines[i:j])
+				i = j
+				continue
+			}
+		}
+
+		if level := headingLevel(l, bodySize); level > 0 {
+			fmt.Fprintf(out, "%s %s\n\n", strings.Repeat("#", level), l.text())
+			i++
+			continue
+		}
+
+		// a paragraph runs until a heading, a table or a wide vertical gap
+		out.WriteString(l.text())
+		i++
+		for i < len(lines) {
+			next := lines[i]
+			if headingLevel(next, bodySize) > 0 || len(next.cells) > 1 || l.y-next.y > max(l.size, 1)*1.8 {
+				break
+			}
+			out.WriteString("\n")
+			out.WriteString(next.text())
+			l = next
+			i++
+		}
+		out.WriteString("\n\n")
+	}
+}
+
+func writePdfTable(out *strings.Builder, rows []pdfLine) {
+	cols := 0
+	for _, r := range rows {
+		cols = max(cols, len(r.cells))
+	}
+	for i, r := range rows {
+		out.WriteString("|")
+		for c := 0; c < cols; c++ {
+			cell := ""
+			if c < len(r.cells) {
+				cell = strings.ReplaceAll(r.cells[c], "|", `\|`)
+			}
+			out.WriteString(" " + cell + " |")
+		}
+		out.WriteString("\n")
+		if i == 0 {
+			out.WriteString("|" + strings.Repeat(" --- |", cols) + "\n")
+		}
+	}
+	out.WriteString("\n")
+}
@@ -0,0 +1,450 @@
+package fetchurl
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// PolicyAllow lets matching URLs be fetched.
+	PolicyAllow = "allow"
+	// PolicyDeny refuses matching URLs.
+	PolicyDeny = "deny"
+	// PolicyRequireAuth lets matching URLs be fetched by authenticated
+	// callers only (see WithAuthenticatedCaller).
+	PolicyRequireAuth = "require_auth"
+)
+
+// PolicyRule is one entry of the URL policy. Every matcher that is set must
+// match; a list matches if any of its entries does. A rule without matchers
+// matches every URL.
+type PolicyRule struct {
+	Name   string // shown in errors and by "policy test" (default: "#N", its position)
+	Action string // PolicyAllow, PolicyDeny or PolicyRequireAuth
+
+	Schemes []string // ex: https
+	// Hosts are host names, matched case-insensitively. "*.example.com"
+	// matches any subdomain of example.com (but not example.com itself);
+	// other * and ? globs work too.
+	Hosts []string
+	// Ports are ports (ex: 443) or ranges (ex: 8000-8999). URLs without a
+	// port use their scheme's default.
+	Ports []string
+	// PathPrefixes, Globs and Regex see the URL with its path normalized:
+	// escaped unreserved characters decoded, dot segments resolved and
+	// repeated slashes collapsed.
+	PathPrefixes []string // ex: /docs/
+	Globs        []string // URL globs, as in the allow/deny lists
+	Regex        string   // regular expression (RE2) matched against the whole URL
+	// CIDRs are IP ranges (ex: 10.0.0.0/8) matched against hosts that are
+	// IP addresses. Names aren't resolved; see BlockPrivateIPs for that.
+	CIDRs []string
+}
+
+// PolicyDecision explains how the URL policy treats a URL.
+type PolicyDecision struct {
+	Action string `json:"action"`
+	Rule   string `json:"rule"`             // name of the rule that matched, or "default"
+	Reason string `json:"reason,omitempty"` // what the rule matched
+	// Default is set when no rule matched and the default action applies.
+	Default bool `json:"default,omitempty"`
+	glob    bool // matched by an allow/deny glob
+}
+
+// PolicyDeniedError is returned for URLs the policy refuses.
+type PolicyDeniedError struct {
+	URL      string
+	Decision PolicyDecision
+}
+
+func (e *PolicyDeniedError) Error() string {
+	switch {
+	case e.Decision.Action == PolicyRequireAuth && e.Decision.Default:
+		return fmt.Sprintf("URL %q requires an authenticated caller", e.URL)
+	case e.Decision.Action == PolicyRequireAuth:
+		return fmt.Sprintf("URL %q requires an authenticated caller (policy rule %q)", e.URL, e.Decision.Rule)
+	case e.Decision.Default:
+		return fmt.Sprintf("URL %q is denied by default", e.URL)
+	case e.Decision.glob:
+		return fmt.Sprintf("URL %q is denied by policy", e.URL)
+	}
+	return fmt.Sprintf("URL %q is denied by policy rule %q", e.URL, e.Decision.Rule)
+}
+
+// URLPolicy is a compiled list of PolicyRules. The first rule that matches a
+// URL decides; if none does, the default action applies.
+type URLPolicy struct {
+	rules         []*compiledRule
+	defaultAction string
+	empty         bool // no rules and an allow default
+}
+
+type compiledRule struct {
+	name    string
+	glob    bool // an allow/deny glob
+	action  string
+	schemes []string
+	hosts   []hostMatcher
+	ports   [][2]int
+	paths   []string
+	globs   []*regexp.Regexp
+	globSrc []string
+	regex   *regexp.Regexp
+	cidrs   []*net.IPNet
+}
+
+type hostMatcher struct {
+	pattern string
+	re      *regexp.Regexp // nil for an exact match
+}
+
+// NewURLPolicy compiles rules, followed by the allow and deny globs (which
+// keep their old meaning: an allow glob wins over a deny glob). An empty
+// defaultAction is PolicyDeny when there are allow globs, as an allow list
+// implies, and PolicyAllow otherwise.
+func NewURLPolicy(rules []PolicyRule, allowGlobs, denyGlobs []string, defaultAction string) (*URLPolicy, error) {
+	p := &URLPolicy{}
+	hasAllowList := len(normalizeList(allowGlobs)) > 0
+	for i, rule := range rules {
+		c, err := compileRule(rule, i+1)
+		if err != nil {
+			return nil, err
+		}
+		p.rules = append(p.rules, c)
+	}
+	for _, globs := range []struct {
+		action   string
+		patterns []string
+	}{{PolicyAllow, allowGlobs}, {PolicyDeny, denyGlobs}} {
+		for _, pattern := range normalizeList(globs.patterns) {
+			re, err := globToRegex(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid glob pattern %q: %w", pattern, err)
+			}
+			p.rules = append(p.rules, &compiledRule{
+				name:    fmt.Sprintf("%s glob %q", globs.action, pattern),
+				glob:    true,
+				action:  globs.action,
+				globs:   []*regexp.Regexp{re},
+				globSrc: []string{pattern},
+			})
+		}
+	}
+
+	switch defaultAction {
+	case "":
+		p.defaultAction = PolicyAllow
+		if hasAllowList {
+			p.defaultAction = PolicyDeny
+		}
+	case PolicyAllow, PolicyDeny, PolicyRequireAuth:
+		p.defaultAction = defaultAction
+	default:
+		return nil, fmt.Errorf("invalid policy default %q (expected allow, deny or require_auth)", defaultAction)
+	}
+	p.empty = len(p.rules) == 0 && p.defaultAction == PolicyAllow
+	return p, nil
+}
+
+func normalizeList(values []string) []string {
+	var out []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func compileRule(rule PolicyRule, n int) (*compiledRule, error) {
+	c := &compiledRule{name: strings.TrimSpace(rule.Name), action: strings.ToLower(strings.TrimSpace(rule.Action))}
+	if c.name == "" {
+		c.name = fmt.Sprintf("#%d", n)
+	}
+	switch c.action {
+	case PolicyAllow, PolicyDeny, PolicyRequireAuth:
+	case "":
+		return nil, fmt.Errorf("policy rule %q: missing action (allow, deny or require_auth)", c.name)
+	default:
+		return nil, fmt.Errorf("policy rule %q: invalid action %q (expected allow, deny or require_auth)", c.name, rule.Action)
+	}
+
+	for _, s := range normalizeList(rule.Schemes) {
+		c.schemes = append(c.schemes, strings.ToLower(strings.TrimSuffix(s, "://")))
+	}
+	for _, h := range normalizeList(rule.Hosts) {
+		h = strings.TrimRight(strings.ToLower(h), ".")
+		m := hostMatcher{pattern: h}
+		if strings.ContainsAny(h, "*?") {
+			re, err := globToRegex(h)
+			if err != nil {
+				return nil, fmt.Errorf("policy rule %q: invalid host %q: %w", c.name, h, err)
+			}
+			m.re = re
+		}
+		c.hosts = append(c.hosts, m)
+	}
+	for _, port := range normalizeList(rule.Ports) {
+		lo, hi, isRange := strings.Cut(port, "-")
+		if !isRange {
+			hi = lo
+		}
+		from, err1 := strconv.Atoi(strings.TrimSpace(lo))
+		to, err2 := strconv.Atoi(strings.TrimSpace(hi))
+		if err1 != nil || err2 != nil || from < 1 || to > 65535 || from > to {
+			return nil, fmt.Errorf("policy rule %q: invalid port %q (expected a port or a range such as 8000-8999)", c.name, port)
+		}
+		c.ports = append(c.ports, [2]int{from, to})
+	}
+	for _, prefix := range normalizeList(rule.PathPrefixes) {
+		if !strings.HasPrefix(prefix, "/") {
+			prefix = "/" + prefix
+		}
+		c.paths = append(c.paths, prefix)
+	}
+	for _, pattern := range normalizeList(rule.Globs) {
+		re, err := globToRegex(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("policy rule %q: invalid glob %q: %w", c.name, pattern, err)
+		}
+		c.globs = append(c.globs, re)
+		c.globSrc = append(c.globSrc, pattern)
+	}
+	if rule.Regex != "" {
+		re, err := regexp.Compile(rule.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("policy rule %q: invalid regex: %w", c.name, err)
+		}
+		c.regex = re
+	}
+	for _, cidr := range normalizeList(rule.CIDRs) {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("policy rule %q: invalid CIDR %q", c.name, cidr)
+		}
+		c.cidrs = append(c.cidrs, ipNet)
+	}
+	return c, nil
+}
+
+// Evaluate returns the decision for rawURL.
+func (p *URLPolicy) Evaluate(rawURL string) PolicyDecision {
+	rawURL = strings.TrimSpace(rawURL)
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return PolicyDecision{Action: PolicyDeny, Rule: "default", Reason: fmt.Sprintf("invalid URL: %v", err), Default: true}
+	}
+	// "host." is the same host as "host" to DNS, Go and Chrome, so it must
+	// match the same rules
+	if host := u.Hostname(); strings.HasSuffix(host, ".") {
+		host = strings.TrimRight(host, ".")
+		if port := u.Port(); port != "" {
+			u.Host = net.JoinHostPort(host, port)
+		} else {
+			u.Host = host
+		}
+		rawURL = u.String()
+	}
+	// servers treat "/%61dmin", "//admin" and "/docs/../admin" as "/admin",
+	// so every matcher sees the path that way
+	if u.Opaque == "" && u.EscapedPath() != "" {
+		if clean := cleanPath(u.EscapedPath()); clean != u.EscapedPath() {
+			u.Path, _ = url.PathUnescape(clean)
+			u.RawPath = clean
+			rawURL = u.String()
+		}
+	}
+	for _, rule := range p.rules {
+		if reasons, ok := rule.match(rawURL, u); ok {
+			d := PolicyDecision{Action: rule.action, Rule: rule.name, Reason: strings.Join(reasons, ", "), glob: rule.glob}
+			if d.Reason == "" {
+				d.Reason = "the rule matches every URL"
+			}
+			return d
+		}
+	}
+	return PolicyDecision{Action: p.defaultAction, Rule: "default", Reason: "no rule matched", Default: true}
+}
+
+// cleanPath decodes the percent-escapes of unreserved characters in an
+// escaped URL path, then resolves its dot segments and repeated slashes. Other
+// escapes (ex: %2F) are kept, since they mean something else unescaped.
+func cleanPath(escaped string) string {
+	var b strings.Builder
+	for i := 0; i < len(escaped); i++ {
+		if escaped[i] == '%' && i+2 < len(escaped) {
+			if c, err := strconv.ParseUint(escaped[i+1:i+3], 16, 8); err == nil && isUnreserved(byte(c)) {
+				b.WriteByte(byte(c))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(escaped[i])
+	}
+	decoded := b.String()
+
+	cleaned := path.Clean("/" + decoded)
+	if cleaned != "/" && (strings.HasSuffix(decoded, "/") || strings.HasSuffix(decoded, "/.") || strings.HasSuffix(decoded, "/..")) {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+// isUnreserved reports whether c is an unreserved URL character (RFC 3986).
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+// match reports whether the rule matches u, and what each matcher matched.
+func (r *compiledRule) match(rawURL string, u *url.URL) ([]string, bool) {
+	var reasons []string
+	scheme := strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+
+	if len(r.schemes) > 0 {
+		if !contains(r.schemes, scheme) {
+			return nil, false
+		}
+		reasons = append(reasons, fmt.Sprintf("scheme %q", scheme))
+	}
+	if len(r.hosts) > 0 {
+		matched := ""
+		for _, m := range r.hosts {
+			if (m.re == nil && m.pattern == host) || (m.re != nil && m.re.MatchString(host)) {
+				matched = m.pattern
+				break
+			}
+		}
+		if matched == "" {
+			return nil, false
+		}
+		reasons = append(reasons, fmt.Sprintf("host %q matches %q", host, matched))
+	}
+	if len(r.ports) > 0 {
+		port := urlPort(u)
+		ok := false
+		for _, rng := range r.ports {
+			if port >= rng[0] && port <= rng[1] {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return nil, false
+		}
+		reasons = append(reasons, fmt.Sprintf("port %d", port))
+	}
+	if len(r.paths) > 0 {
+		path := u.EscapedPath()
+		if path == "" {
+			path = "/"
+		}
+		matched := ""
+		for _, prefix := range r.paths {
+			if strings.HasPrefix(path, prefix) {
+				matched = prefix
+				break
+			}
+		}
+		if matched == "" {
+			return nil, false
+		}
+		reasons = append(reasons, fmt.Sprintf("path %q starts with %q", path, matched))
+	}
+	if len(r.globs) > 0 {
+		matched := ""
+		for i, re := range r.globs {
+			if re.MatchString(rawURL) {
+				matched = r.globSrc[i]
+				break
+			}
+		}
+		if matched == "" {
+			return nil, false
+		}
+		reasons = append(reasons, fmt.Sprintf("URL matches glob %q", matched))
+	}
+	if r.regex != nil {
+		if !r.regex.MatchString(rawURL) {
+			return nil, false
+		}
+		reasons = append(reasons, fmt.Sprintf("URL matches regex %q", r.regex.String()))
+	}
+	if len(r.cidrs) > 0 {
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return nil, false
+		}
+		matched := ""
+		for _, n := range r.cidrs {
+			if n.Contains(ip) {
+				matched = n.String()
+				break
+			}
+		}
+		if matched == "" {
+			return nil, false
+		}
+		reasons = append(reasons, fmt.Sprintf("address %s is in %s", ip, matched))
+	}
+	return reasons, true
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// urlPort returns u's port, or its scheme's default.
+func urlPort(u *url.URL) int {
+	if port, err := strconv.Atoi(u.Port()); err == nil {
+		return port
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "https", "wss":
+		return 443
+	case "http", "ws":
+		return 80
+	}
+	return 0
+}
+
+type authenticatedCallerKey struct{}
+
+// WithAuthenticatedCaller marks fetches made with ctx as made for an
+// authenticated caller, who may fetch URLs of PolicyRequireAuth rules.
+func WithAuthenticatedCaller(ctx context.Context) context.Context {
+	return context.WithValue(ctx, authenticatedCallerKey{}, true)
+}
+
+func callerAuthenticated(ctx context.Context) bool {
+	ok, _ := ctx.Value(authenticatedCallerKey{}).(bool)
+	return ok
+}
+
+type callerPolicyKey struct{}
+
+// WithCallerPolicy layers p on the fetcher's URL policy for fetches made with
+// ctx: a URL must be allowed by both (ex: the allow/deny globs of an API
+// key). Redirects, crawled links and browser requests are checked too.
+func WithCallerPolicy(ctx context.Context, p *URLPolicy) context.Context {
+	if p == nil || p.empty {
+		return ctx
+	}
+	return context.WithValue(ctx, callerPolicyKey{}, p)
+}
+
+func callerPolicy(ctx context.Context) *URLPolicy {
+	p, _ := ctx.Value(callerPolicyKey{}).(*URLPolicy)
+	return p
+}
@@ -0,0 +1,201 @@
+package fetchurl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+	"github.com/chromedp/chromedp/device"
+)
+
+// FetchProfile customizes how the browser loads pages whose URL matches the
+// Url glob. The first matching profile is applied to the tab before
+// navigating. Zero-valued fields leave the browser defaults alone.
+type FetchProfile struct {
+	Url               string
+	Headers           map[string]string // extra headers, only sent to URLs matching Url
+	Cookies           []ProfileCookie   // sent where their Domain and Path match, as browsers do
+	AcceptLanguage    string
+	UserAgent         string
+	ViewportWidth     int
+	ViewportHeight    int
+	Device            string // device name to emulate (ex: "iPhone 12", "Pixel 5")
+	DisableJavaScript bool
+	Wait              WaitStrategy
+	Actions           []PageAction      // run on every matching page before it is captured
+	Blocking          *ResourceBlocking // replaces WebFetcherOptions.Blocking for matching pages (optional)
+}
+
+// ProfileCookie is a cookie to set before navigating. If Domain is empty the
+// cookie is scoped to the URL being fetched.
+//
+// Note: tabs share the browser's cookie jar, so these cookies are also sent
+// by later requests to the same site.
+type ProfileCookie struct {
+	Name   string
+	Value  string
+	Domain string
+	Path   string
+}
+
+// LookupDevice returns the chromedp device definition with the given name
+// (case-insensitive), as listed in github.com/chromedp/chromedp/device.
+func LookupDevice(name string) (device.Info, error) {
+	for d := device.Reset + 1; d <= device.MotoG4landscape; d++ {
+		if info := d.Device(); strings.EqualFold(info.Name, name) {
+			return info, nil
+		}
+	}
+	return device.Info{}, fmt.Errorf("unknown device: %s", name)
+}
+
+// profileFor returns the first Profiles entry matching targetURL, or nil.
+func (w *WebFetcher) profileFor(targetURL string) *FetchProfile {
+	for i := range w.opts.Profiles {
+		if match, _ := matchGlobList(targetURL, []string{w.opts.Profiles[i].Url}); match {
+			return &w.opts.Profiles[i]
+		}
+	}
+	return nil
+}
+
+// matches reports whether the profile's Url glob matches rawURL.
+func (p *FetchProfile) matches(rawURL string) bool {
+	match, _ := matchGlobList(rawURL, []string{p.Url})
+	return match
+}
+
+// hasHeader reports whether the profile sets the header name.
+func (p *FetchProfile) hasHeader(name string) bool {
+	for k := range p.Headers {
+		if strings.EqualFold(k, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// cookiesFor returns the profile's cookies a browser would send to u, given
+// that they were set for targetURL: those whose Domain (or, without one,
+// targetURL's host) and Path match u. As with Chrome's, cookies without a
+// Path get "/" with a Domain, and targetURL's directory without one.
+func (p *FetchProfile) cookiesFor(targetURL *url.URL, u *url.URL) []*http.Cookie {
+	host := strings.ToLower(u.Hostname())
+	var cookies []*http.Cookie
+	for _, c := range p.Cookies {
+		cookiePath := c.Path
+		if domain := strings.ToLower(strings.TrimPrefix(c.Domain, ".")); domain != "" {
+			if host != domain && !strings.HasSuffix(host, "."+domain) {
+				continue
+			}
+			if cookiePath == "" {
+				cookiePath = "/"
+			}
+		} else {
+			if host != strings.ToLower(targetURL.Hostname()) {
+				continue
+			}
+			if cookiePath == "" {
+				cookiePath = defaultCookiePath(targetURL)
+			}
+		}
+		if !cookiePathMatches(u.EscapedPath(), cookiePath) {
+			continue
+		}
+		cookies = append(cookies, &http.Cookie{Name: c.Name, Value: c.Value})
+	}
+	return cookies
+}
+
+// defaultCookiePath is the path of a cookie set for u without one: the
+// directory of u's path (RFC 6265, section 5.1.4).
+func defaultCookiePath(u *url.URL) string {
+	p := u.EscapedPath()
+	if i := strings.LastIndex(p, "/"); i > 0 {
+		return p[:i]
+	}
+	return "/"
+}
+
+// cookiePathMatches reports whether a cookie with cookiePath is sent for
+// requests to reqPath (RFC 6265, section 5.1.4).
+func cookiePathMatches(reqPath, cookiePath string) bool {
+	if reqPath == "" {
+		reqPath = "/"
+	}
+	if reqPath == cookiePath {
+		return true
+	}
+	return strings.HasPrefix(reqPath, cookiePath) && (strings.HasSuffix(cookiePath, "/") || reqPath[len(cookiePath)] == '/')
+}
+
+// setup applies the profile to the current tab. It must run before
+// navigating. Its headers are added by the tab's requestBlocker, which only
+// sends them to URLs the profile matches. A nil profile is a no-op.
+func (p *FetchProfile) setup(targetURL string) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if p == nil {
+			return nil
+		}
+
+		userAgent := p.UserAgent
+		if p.Device != "" {
+			dev, err := LookupDevice(p.Device)
+			if err != nil {
+				return err
+			}
+			if err := chromedp.Emulate(dev).Do(ctx); err != nil {
+				return fmt.Errorf("emulating device %s: %w", p.Device, err)
+			}
+			if userAgent == "" {
+				userAgent = dev.UserAgent
+			}
+		}
+
+		if p.ViewportWidth > 0 && p.ViewportHeight > 0 {
+			if err := chromedp.EmulateViewport(int64(p.ViewportWidth), int64(p.ViewportHeight)).Do(ctx); err != nil {
+				return fmt.Errorf("setting viewport: %w", err)
+			}
+		}
+
+		if userAgent != "" || p.AcceptLanguage != "" {
+			if userAgent == "" {
+				userAgent = defaultUserAgent
+			}
+			override := emulation.SetUserAgentOverride(userAgent)
+			if p.AcceptLanguage != "" {
+				override = override.WithAcceptLanguage(p.AcceptLanguage)
+			}
+			if err := override.Do(ctx); err != nil {
+				return fmt.Errorf("setting user agent: %w", err)
+			}
+		}
+
+		for _, c := range p.Cookies {
+			set := network.SetCookie(c.Name, c.Value)
+			if c.Domain != "" {
+				set = set.WithDomain(c.Domain)
+			} else {
+				set = set.WithURL(targetURL)
+			}
+			if c.Path != "" {
+				set = set.WithPath(c.Path)
+			}
+			if err := set.Do(ctx); err != nil {
+				return fmt.Errorf("setting cookie %s: %w", c.Name, err)
+			}
+		}
+
+		if p.DisableJavaScript {
+			if err := emulation.SetScriptExecutionDisabled(true).Do(ctx); err != nil {
+				return fmt.Errorf("disabling javascript: %w", err)
+			}
+		}
+		return nil
+	})
+}
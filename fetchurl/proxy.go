@@ -0,0 +1,290 @@
+package fetchurl
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/chromedp/chromedp"
+)
+
+// ProxyDirect is the ProxyRule.Proxy value that sends matching requests
+// without a proxy.
+const ProxyDirect = "direct"
+
+// ProxyConfig routes outbound requests (browser, downloads, search and the
+// summary LLM) through a proxy. The zero value connects directly, or through
+// the proxy set in the HTTP_PROXY/HTTPS_PROXY environment for the Go
+// clients.
+type ProxyConfig struct {
+	// URL is the default proxy: http://, https://, socks5:// or socks5h://,
+	// with an optional user:password@. Empty for none.
+	URL string
+	// Bypass lists the hosts that always go direct: a domain (which also
+	// matches its subdomains), a host glob (ex: *.corp.example.com) or an IP
+	// range (ex: 10.0.0.0/8). Loopback hosts always go direct.
+	Bypass []string
+	// Rules override the proxy for matching URLs. The first match wins.
+	Rules []ProxyRule
+}
+
+// ProxyRule sends requests for URLs matching a glob through another proxy,
+// or directly.
+type ProxyRule struct {
+	URL   string // URL glob, as in the allow/deny lists
+	Proxy string // proxy URL, or ProxyDirect
+}
+
+// IsZero reports whether no proxy is configured.
+func (c ProxyConfig) IsZero() bool {
+	return c.URL == "" && len(c.Rules) == 0
+}
+
+// proxyRouter picks the proxy for each request.
+type proxyRouter struct {
+	def       *url.URL // nil for direct
+	rules     []proxyRoute
+	bypass    []string
+	bypassNet []*net.IPNet
+}
+
+type proxyRoute struct {
+	re    *regexp.Regexp
+	proxy *url.URL // nil for direct
+}
+
+// newProxyRouter validates cfg. It returns nil if no proxy is configured.
+func newProxyRouter(cfg ProxyConfig) (*proxyRouter, error) {
+	if cfg.IsZero() {
+		return nil, nil
+	}
+	r := &proxyRouter{}
+	var err error
+	if r.def, err = parseProxyURL(cfg.URL); err != nil {
+		return nil, err
+	}
+	for _, rule := range cfg.Rules {
+		re, err := globToRegex(strings.TrimSpace(rule.URL))
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy rule glob %q: %w", rule.URL, err)
+		}
+		route := proxyRoute{re: re}
+		if !strings.EqualFold(strings.TrimSpace(rule.Proxy), ProxyDirect) {
+			if route.proxy, err = parseProxyURL(rule.Proxy); err != nil {
+				return nil, fmt.Errorf("proxy rule %s: %w", rule.URL, err)
+			}
+			if route.proxy == nil {
+				return nil, fmt.Errorf("proxy rule %s: missing proxy (use %q to connect directly)", rule.URL, ProxyDirect)
+			}
+		}
+		r.rules = append(r.rules, route)
+	}
+	for _, b := range cfg.Bypass {
+		b = strings.ToLower(strings.TrimSpace(b))
+		if b == "" {
+			continue
+		}
+		if _, ipNet, err := net.ParseCIDR(b); err == nil {
+			r.bypassNet = append(r.bypassNet, ipNet)
+			continue
+		}
+		r.bypass = append(r.bypass, strings.TrimPrefix(b, "."))
+	}
+	return r, nil
+}
+
+// parseProxyURL parses a proxy URL, or returns nil for "".
+func parseProxyURL(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("invalid proxy URL %q (expected http://, https://, socks5:// or socks5h://)", u.Redacted())
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q: missing host", u.Redacted())
+	}
+	return u, nil
+}
+
+// proxyFor returns the proxy for a request to u, or nil to connect directly.
+func (r *proxyRouter) proxyFor(u *url.URL) *url.URL {
+	if r == nil || r.bypassed(u.Hostname()) {
+		return nil
+	}
+	target := u.String()
+	for _, route := range r.rules {
+		if route.re.MatchString(target) {
+			return route.proxy
+		}
+	}
+	return r.def
+}
+
+// bypassed reports whether host always goes direct.
+func (r *proxyRouter) bypassed(host string) bool {
+	host = strings.ToLower(host)
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip.IsLoopback() {
+			return true
+		}
+		for _, n := range r.bypassNet {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+	}
+	for _, b := range r.bypass {
+		if strings.ContainsAny(b, "*?") {
+			if matched, _ := matchGlobList(host, []string{b}); matched {
+				return true
+			}
+		} else if host == b || strings.HasSuffix(host, "."+b) {
+			return true
+		}
+	}
+	return false
+}
+
+// credentials returns the user name and password of the configured proxy at
+// hostPort (ex: "proxy.corp:3128"), if it has any.
+func (r *proxyRouter) credentials(hostPort string) (string, string, bool) {
+	if r == nil {
+		return "", "", false
+	}
+	for _, u := range r.proxies() {
+		if u.User == nil || !strings.EqualFold(proxyHostPort(u), hostPort) {
+			continue
+		}
+		password, _ := u.User.Password()
+		return u.User.Username(), password, true
+	}
+	return "", "", false
+}
+
+// hasCredentials reports whether any of the proxies needs a login.
+func (r *proxyRouter) hasCredentials() bool {
+	if r == nil {
+		return false
+	}
+	for _, u := range r.proxies() {
+		if u.User != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// socksLogin reports whether a SOCKS proxy has credentials, which Chrome
+// doesn't support.
+func (r *proxyRouter) socksLogin() bool {
+	if r == nil {
+		return false
+	}
+	for _, u := range r.proxies() {
+		if u.User != nil && strings.HasPrefix(u.Scheme, "socks") {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *proxyRouter) proxies() []*url.URL {
+	var out []*url.URL
+	if r.def != nil {
+		out = append(out, r.def)
+	}
+	for _, route := range r.rules {
+		if route.proxy != nil {
+			out = append(out, route.proxy)
+		}
+	}
+	return out
+}
+
+// proxyHostPort returns the proxy's host:port, with the scheme's default port
+// if there is none.
+func proxyHostPort(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "https":
+			port = "443"
+		case "socks5", "socks5h":
+			port = "1080"
+		default:
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// chromeFlags returns the Chrome command line flags for the proxy setup.
+// Chrome takes a PAC script, so per-URL rules and the bypass list can be
+// applied in the browser too. Chrome only passes the scheme and host of
+// https:// URLs to the script, so rule globs with a path only match plain
+// http:// URLs there. Credentials aren't part of the flags; they are
+// answered per tab (see requestBlocker).
+func (r *proxyRouter) chromeFlags() []chromedp.ExecAllocatorOption {
+	if r == nil {
+		return nil
+	}
+	pac := base64.StdEncoding.EncodeToString([]byte(r.pacScript()))
+	return []chromedp.ExecAllocatorOption{
+		chromedp.Flag("proxy-pac-url", "data:application/x-ns-proxy-autoconfig;base64,"+pac),
+	}
+}
+
+// pacScript renders the router as a proxy auto-config script.
+func (r *proxyRouter) pacScript() string {
+	var b strings.Builder
+	b.WriteString("function FindProxyForURL(url, host) {\n")
+	b.WriteString("  host = host.toLowerCase();\n")
+	b.WriteString(`  if (host == "localhost" || dnsDomainIs(host, ".localhost") || /^127\./.test(host) || host == "[::1]" || host == "::1") return "DIRECT";` + "\n")
+	b.WriteString(`  var ip = /^\d+\.\d+\.\d+\.\d+$/.test(host);` + "\n")
+	for _, n := range r.bypassNet {
+		if n.IP.To4() == nil {
+			continue
+		}
+		fmt.Fprintf(&b, "  if (ip && isInNet(host, %q, %q)) return \"DIRECT\";\n", n.IP.String(), net.IP(n.Mask).String())
+	}
+	for _, h := range r.bypass {
+		if strings.ContainsAny(h, "*?") {
+			fmt.Fprintf(&b, "  if (shExpMatch(host, %q)) return \"DIRECT\";\n", h)
+		} else {
+			fmt.Fprintf(&b, "  if (host == %q || dnsDomainIs(host, %q)) return \"DIRECT\";\n", h, "."+h)
+		}
+	}
+	for _, route := range r.rules {
+		fmt.Fprintf(&b, "  if (/%s/.test(url)) return %q;\n", strings.ReplaceAll(route.re.String(), "/", `\/`), pacProxy(route.proxy))
+	}
+	fmt.Fprintf(&b, "  return %q;\n}\n", pacProxy(r.def))
+	return b.String()
+}
+
+// pacProxy returns the PAC result for a proxy URL.
+func pacProxy(u *url.URL) string {
+	if u == nil {
+		return "DIRECT"
+	}
+	switch u.Scheme {
+	case "https":
+		return "HTTPS " + proxyHostPort(u)
+	case "socks5", "socks5h":
+		return "SOCKS5 " + proxyHostPort(u)
+	}
+	return "PROXY " + proxyHostPort(u)
+}
@@ -0,0 +1,239 @@
+package fetchurl
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	ExtractionBody        = "body"        // always capture the whole body
+	ExtractionSelector    = "selector"    // use the matching selector, else the body (default)
+	ExtractionReadability = "readability" // use the matching selector, else extract the main content
+)
+
+// ValidExtractionMode reports whether mode is one of the Extraction constants.
+func ValidExtractionMode(mode string) bool {
+	switch mode {
+	case ExtractionBody, ExtractionSelector, ExtractionReadability:
+		return true
+	}
+	return false
+}
+
+// Class/id patterns used to score nodes, following Mozilla's Readability.
+var (
+	unlikelyCandidates = regexp.MustCompile(`(?i)-ad-|ai2html|banner|breadcrumbs|combx|comment|community|consent|cookie|cover-wrap|disqus|extra|footer|gdpr|legends|menu|newsletter|related|remark|replies|rss|share|shoutbox|sidebar|skyscraper|social|sponsor|subscribe|supplemental|ad-break|agegate|pagination|pager|popup`)
+	maybeCandidate     = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow`)
+	positiveNames      = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|post|text|blog|story`)
+	negativeNames      = regexp.MustCompile(`(?i)-ad-|hidden|^hid$| hid$| hid |^hid |banner|combx|comment|com-|contact|consent|cookie|foot|footer|footnote|gdpr|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|widget`)
+)
+
+// elements that never hold article content
+var strippedElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Nav: true,
+	atom.Footer: true, atom.Aside: true, atom.Form: true, atom.Iframe: true,
+	atom.Svg: true, atom.Button: true, atom.Input: true, atom.Select: true,
+	atom.Textarea: true, atom.Dialog: true,
+}
+
+// elements whose text is scored as a paragraph
+var scoredElements = map[atom.Atom]bool{
+	atom.P: true, atom.Pre: true, atom.Td: true, atom.Blockquote: true,
+}
+
+var blockElements = map[atom.Atom]bool{
+	atom.Article: true, atom.Blockquote: true, atom.Div: true, atom.Dl: true,
+	atom.Ol: true, atom.P: true, atom.Pre: true, atom.Section: true,
+	atom.Table: true, atom.Ul: true,
+}
+
+// ExtractMainContent returns the HTML of the main article in src, dropping
+// navigation, footers, cookie notices, related-article lists and the like.
+// Nodes are scored the way Mozilla's Readability does it: paragraphs add to
+// the score of their ancestors based on their length and comma count, class
+// and id names push the score up or down, and link-heavy nodes are
+// penalized. The best scoring node is kept along with any siblings that look
+// like part of the same article.
+//
+// If no candidate is found, src is returned unchanged.
+func ExtractMainContent(src string) (string, error) {
+	doc, err := html.Parse(strings.NewReader(src))
+	if err != nil {
+		return "", err
+	}
+	root := findFirst(doc, atom.Body)
+	if root == nil {
+		root = doc
+	}
+
+	pruneUnlikely(root)
+
+	scores := map[*html.Node]float64{}
+	var candidates []*html.Node
+	addScore := func(n *html.Node, score float64) {
+		if n == nil || n.Type != html.ElementNode {
+			return
+		}
+		if _, ok := scores[n]; !ok {
+			scores[n] = initialScore(n)
+			candidates = append(candidates, n)
+		}
+		scores[n] += score
+	}
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && (scoredElements[n.DataAtom] || (n.DataAtom == atom.Div && !hasBlockChildren(n))) {
+			text := normalizeSpace(nodeText(n))
+			if len(text) >= 25 {
+				score := 1 + float64(strings.Count(text, ",")) + min(float64(len(text)/100), 3)
+				// parent gets the full score, grandparent half, then a third
+				for level, ancestor := 0, n.Parent; level < 3 && ancestor != nil && ancestor != root.Parent; level, ancestor = level+1, ancestor.Parent {
+					divider := 1.0
+					if level == 1 {
+						divider = 2
+					} else if level > 1 {
+						divider = float64(level * 3)
+					}
+					addScore(ancestor, score/divider)
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+
+	var top *html.Node
+	topScore := 0.0
+	for _, n := range candidates {
+		scores[n] *= 1 - linkDensity(n)
+		if top == nil || scores[n] > topScore {
+			top, topScore = n, scores[n]
+		}
+	}
+	if top == nil {
+		return src, nil
+	}
+
+	// Siblings with a decent score, or paragraphs that read like prose, are
+	// usually part of the same article (ex: a lead paragraph outside the
+	// main content div).
+	keep := []*html.Node{top}
+	if top != root && top.Parent != nil {
+		keep = nil
+		threshold := max(10, topScore*0.2)
+		for s := top.Parent.FirstChild; s != nil; s = s.NextSibling {
+			if s.Type != html.ElementNode {
+				continue
+			}
+			include := s == top
+			if score, ok := scores[s]; ok && score >= threshold {
+				include = true
+			} else if s.DataAtom == atom.P {
+				text := normalizeSpace(nodeText(s))
+				density := linkDensity(s)
+				if len(text) > 80 && density < 0.25 {
+					include = true
+				} else if len(text) > 0 && density == 0 && strings.Contains(text, ". ") {
+					include = true
+				}
+			}
+			if include {
+				keep = append(keep, s)
+			}
+		}
+	}
+
+	var out strings.Builder
+	out.WriteString("<div>")
+	for _, n := range keep {
+		if err := html.Render(&out, n); err != nil {
+			return "", err
+		}
+	}
+	out.WriteString("</div>")
+	return out.String(), nil
+}
+
+// pruneUnlikely removes elements that never hold article content, and those
+// whose class or id marks them as page furniture.
+func pruneUnlikely(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.ElementNode {
+			names := attr(c, "class") + " " + attr(c, "id")
+			protected := c.DataAtom == atom.Article || c.DataAtom == atom.Main || c.DataAtom == atom.Body
+			if strippedElements[c.DataAtom] || attr(c, "aria-hidden") == "true" || attr(c, "role") == "navigation" ||
+				(!protected && unlikelyCandidates.MatchString(names) && !maybeCandidate.MatchString(names)) {
+				n.RemoveChild(c)
+			} else {
+				pruneUnlikely(c)
+			}
+		}
+		c = next
+	}
+}
+
+func initialScore(n *html.Node) float64 {
+	score := 0.0
+	switch n.DataAtom {
+	case atom.Article, atom.Main:
+		score = 10
+	case atom.Div:
+		score = 5
+	case atom.Pre, atom.Td, atom.Blockquote:
+		score = 3
+	case atom.Address, atom.Ol, atom.Ul, atom.Dl, atom.Dd, atom.Dt, atom.Li, atom.Form:
+		score = -3
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Th:
+		score = -5
+	}
+	return score + classWeight(n)
+}
+
+func classWeight(n *html.Node) float64 {
+	weight := 0.0
+	for _, name := range []string{attr(n, "class"), attr(n, "id")} {
+		if name == "" {
+			continue
+		}
+		if negativeNames.MatchString(name) {
+			weight -= 25
+		}
+		if positiveNames.MatchString(name) {
+			weight += 25
+		}
+	}
+	return weight
+}
+
+// linkDensity is the fraction of n's text that is inside links.
+func linkDensity(n *html.Node) float64 {
+	total := len(normalizeSpace(nodeText(n)))
+	if total == 0 {
+		return 0
+	}
+	linked := 0
+	for _, a := range findAll(n, atom.A) {
+		linked += len(normalizeSpace(nodeText(a)))
+	}
+	return float64(linked) / float64(total)
+}
+
+func hasBlockChildren(n *html.Node) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && blockElements[c.DataAtom] {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,244 @@
+package fetchurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+const (
+	WaitLoad        = "load"
+	WaitNetworkIdle = "network-idle"
+	WaitSelector    = "selector"
+	WaitJS          = "js"
+	WaitDelay       = "delay"
+	WaitDOMQuiet    = "dom-quiet"
+
+	// DefaultWaitTimeout bounds how long a readiness strategy may wait before
+	// the page is captured anyway.
+	DefaultWaitTimeout = 10 * time.Second
+
+	defaultQuietPeriod = 500 * time.Millisecond
+)
+
+// WaitStrategy describes how to decide that a page is ready to be captured
+// after navigation. The zero value only waits for the load event, which is
+// what chromedp.Navigate does on its own.
+//
+// Strategies are written as "kind[:argument]":
+//
+//	load
+//	network-idle[:500ms]   no requests in flight for the given period
+//	selector:#content      an element matching the CSS selector exists
+//	js:window.appReady     the JS expression is truthy
+//	delay:2s               a fixed delay
+//	dom-quiet[:500ms]      no DOM mutations for the given period
+type WaitStrategy struct {
+	Kind       string
+	Selector   string
+	Expression string
+	Duration   time.Duration
+}
+
+// ParseWaitStrategy parses a readiness strategy in the "kind[:argument]" form
+// described on WaitStrategy.
+func ParseWaitStrategy(spec string) (WaitStrategy, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return WaitStrategy{}, nil
+	}
+
+	kind, arg, _ := strings.Cut(spec, ":")
+	kind = strings.ToLower(strings.TrimSpace(kind))
+	arg = strings.TrimSpace(arg)
+
+	parseDuration := func(def time.Duration) (time.Duration, error) {
+		if arg == "" {
+			if def == 0 {
+				return 0, fmt.Errorf("wait strategy %q requires a duration", kind)
+			}
+			return def, nil
+		}
+		d, err := time.ParseDuration(arg)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration in wait strategy %q: %w", spec, err)
+		}
+		return d, nil
+	}
+
+	switch kind {
+	case WaitLoad:
+		return WaitStrategy{Kind: WaitLoad}, nil
+	case WaitNetworkIdle, WaitDOMQuiet:
+		d, err := parseDuration(defaultQuietPeriod)
+		if err != nil {
+			return WaitStrategy{}, err
+		}
+		return WaitStrategy{Kind: kind, Duration: d}, nil
+	case WaitDelay:
+		d, err := parseDuration(0)
+		if err != nil {
+			return WaitStrategy{}, err
+		}
+		return WaitStrategy{Kind: kind, Duration: d}, nil
+	case WaitSelector:
+		if arg == "" {
+			return WaitStrategy{}, fmt.Errorf("wait strategy %q requires a CSS selector", kind)
+		}
+		return WaitStrategy{Kind: kind, Selector: arg}, nil
+	case WaitJS:
+		if arg == "" {
+			return WaitStrategy{}, fmt.Errorf("wait strategy %q requires a JS expression", kind)
+		}
+		return WaitStrategy{Kind: kind, Expression: arg}, nil
+	}
+	return WaitStrategy{}, fmt.Errorf("unknown wait strategy %q (expected load, network-idle, selector, js, delay or dom-quiet)", kind)
+}
+
+func (s WaitStrategy) IsZero() bool {
+	return s.Kind == ""
+}
+
+func (s WaitStrategy) String() string {
+	switch s.Kind {
+	case WaitNetworkIdle, WaitDOMQuiet, WaitDelay:
+		return fmt.Sprintf("%s:%s", s.Kind, s.Duration)
+	case WaitSelector:
+		return fmt.Sprintf("%s:%s", s.Kind, s.Selector)
+	case WaitJS:
+		return fmt.Sprintf("%s:%s", s.Kind, s.Expression)
+	}
+	return s.Kind
+}
+
+// readinessWaiter is a WaitStrategy bound to one tab. Some strategies need to
+// observe the page while it loads, so setup must run before navigating and
+// wait after.
+type readinessWaiter struct {
+	strategy WaitStrategy
+	timeout  time.Duration
+	logger   *slog.Logger
+
+	mu       sync.Mutex
+	inflight map[network.RequestID]bool
+	lastBusy time.Time
+}
+
+func newReadinessWaiter(strategy WaitStrategy, timeout time.Duration, logger *slog.Logger) *readinessWaiter {
+	if timeout <= 0 {
+		timeout = DefaultWaitTimeout
+	}
+	return &readinessWaiter{strategy: strategy, timeout: timeout, logger: logger}
+}
+
+func (r *readinessWaiter) setup() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if r.strategy.Kind != WaitNetworkIdle {
+			return nil
+		}
+		r.inflight = make(map[network.RequestID]bool)
+		r.lastBusy = time.Now()
+		chromedp.ListenTarget(ctx, func(ev any) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			switch ev := ev.(type) {
+			case *network.EventRequestWillBeSent:
+				// long-lived streams never finish, so they don't count
+				if ev.Type == network.ResourceTypeEventSource || ev.Type == network.ResourceTypeWebSocket {
+					return
+				}
+				r.inflight[ev.RequestID] = true
+			case *network.EventLoadingFinished:
+				delete(r.inflight, ev.RequestID)
+			case *network.EventLoadingFailed:
+				delete(r.inflight, ev.RequestID)
+			default:
+				return
+			}
+			r.lastBusy = time.Now()
+		})
+		return nil
+	})
+}
+
+// wait blocks until the page is ready. If the strategy's condition isn't met
+// within the wait timeout, a warning is logged and the page is captured as is.
+func (r *readinessWaiter) wait() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		waitCtx, cancel := context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+
+		var err error
+		switch r.strategy.Kind {
+		case "", WaitLoad:
+			return nil
+		case WaitDelay:
+			err = chromedp.Sleep(r.strategy.Duration).Do(waitCtx)
+		case WaitSelector:
+			err = chromedp.WaitReady(r.strategy.Selector, chromedp.ByQuery).Do(waitCtx)
+		case WaitJS:
+			var res any
+			err = chromedp.Poll(r.strategy.Expression, &res,
+				chromedp.WithPollingInterval(100*time.Millisecond),
+				chromedp.WithPollingTimeout(0),
+			).Do(waitCtx)
+		case WaitNetworkIdle:
+			err = r.waitNetworkIdle(waitCtx)
+		case WaitDOMQuiet:
+			err = r.waitDOMQuiet(waitCtx)
+		default:
+			return fmt.Errorf("unknown wait strategy %q", r.strategy.Kind)
+		}
+
+		if err != nil && errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			r.logger.Warn("timed out waiting for page readiness, capturing page as is", "wait", r.strategy.String(), "timeout", r.timeout)
+			return nil
+		}
+		return err
+	})
+}
+
+func (r *readinessWaiter) waitNetworkIdle(ctx context.Context) error {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		r.mu.Lock()
+		idle := len(r.inflight) == 0 && time.Since(r.lastBusy) >= r.strategy.Duration
+		r.mu.Unlock()
+		if idle {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *readinessWaiter) waitDOMQuiet(ctx context.Context) error {
+	if err := chromedp.Evaluate(`
+		(function() {
+			window.__mcpfurlLastMutation = Date.now();
+			new MutationObserver(function() {
+				window.__mcpfurlLastMutation = Date.now();
+			}).observe(document, {childList: true, subtree: true, attributes: true, characterData: true});
+		})()
+	`, nil).Do(ctx); err != nil {
+		return err
+	}
+
+	quietMs := r.strategy.Duration.Milliseconds()
+	var res any
+	return chromedp.Poll(fmt.Sprintf(`Date.now() - window.__mcpfurlLastMutation >= %d`, quietMs), &res,
+		chromedp.WithPollingInterval(50*time.Millisecond),
+		chromedp.WithPollingTimeout(0),
+	).Do(ctx)
+}
@@ -0,0 +1,150 @@
+package fetchurl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// recordedHeaders are the response headers of the main document kept in
+// FetchedWebPage.Headers.
+var recordedHeaders = []string{"content-type", "last-modified", "etag"}
+
+// RedirectHop is one redirect on the way from TargetURL to CurrentURL. Client
+// side redirects (meta refresh, JavaScript) are recorded with the status of
+// the page that redirected.
+type RedirectHop struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code"`
+	Location   string `json:"location"`
+}
+
+func (h RedirectHop) String() string {
+	return fmt.Sprintf("%d %s -> %s", h.StatusCode, h.URL, h.Location)
+}
+
+// HTTPStatusError is returned when the page was served with a 4xx or 5xx
+// status and the caller didn't ask for error pages.
+type HTTPStatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("%s returned HTTP %d %s", e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// pickHeaders returns the recordedHeaders found in headers, keyed by their
+// lower case name. get looks a header up case-insensitively.
+func pickHeaders(get func(name string) string) map[string]string {
+	var out map[string]string
+	for _, name := range recordedHeaders {
+		if v := get(name); v != "" {
+			if out == nil {
+				out = map[string]string{}
+			}
+			out[name] = v
+		}
+	}
+	return out
+}
+
+// responseRecorder follows the main frame's document requests in a tab and
+// records the final response and every redirect hop.
+type responseRecorder struct {
+	mu        sync.Mutex
+	frameID   cdp.FrameID
+	requestID network.RequestID
+	url       string
+	mimeType  string
+	status    int
+	headers   map[string]string
+	redirects []RedirectHop
+}
+
+// setup starts listening for network events. It must run before navigating.
+func (r *responseRecorder) setup() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		tree, err := page.GetFrameTree().Do(ctx)
+		if err != nil {
+			return fmt.Errorf("getting frame tree: %w", err)
+		}
+		r.frameID = tree.Frame.ID
+
+		chromedp.ListenTarget(ctx, func(ev any) {
+			switch ev := ev.(type) {
+			case *network.EventRequestWillBeSent:
+				if ev.Type != network.ResourceTypeDocument || ev.FrameID != r.frameID {
+					return
+				}
+				r.mu.Lock()
+				defer r.mu.Unlock()
+				if ev.RedirectResponse != nil {
+					// HTTP redirects reuse the request ID
+					r.redirects = append(r.redirects, RedirectHop{
+						URL:        ev.RedirectResponse.URL,
+						StatusCode: int(ev.RedirectResponse.Status),
+						Location:   ev.Request.URL,
+					})
+				} else if r.requestID != "" && r.requestID != ev.RequestID && r.url != "" {
+					// a new navigation after the page loaded
+					r.redirects = append(r.redirects, RedirectHop{
+						URL:        r.url,
+						StatusCode: r.status,
+						Location:   ev.Request.URL,
+					})
+				}
+				r.requestID = ev.RequestID
+			case *network.EventResponseReceived:
+				if ev.Type != network.ResourceTypeDocument || ev.FrameID != r.frameID {
+					return
+				}
+				r.mu.Lock()
+				defer r.mu.Unlock()
+				if ev.RequestID != r.requestID {
+					return
+				}
+				r.url = ev.Response.URL
+				r.mimeType = ev.Response.MimeType
+				r.status = int(ev.Response.Status)
+				r.headers = pickHeaders(func(name string) string {
+					for k, v := range ev.Response.Headers {
+						if strings.EqualFold(k, name) {
+							return fmt.Sprint(v)
+						}
+					}
+					return ""
+				})
+			}
+		})
+		return nil
+	})
+}
+
+// document returns the main document's request ID and media type if it
+// isn't HTML (ex: a PDF or JSON document), or ok=false.
+func (r *responseRecorder) document() (requestID network.RequestID, mediaType string, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	mediaType = mediaTypeOf(r.mimeType)
+	if r.status == 0 || isHTMLType(mediaType) {
+		return "", "", false
+	}
+	return r.requestID, mediaType, true
+}
+
+// apply copies what was recorded to page.
+func (r *responseRecorder) apply(page *FetchedWebPage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	page.StatusCode = r.status
+	page.Headers = r.headers
+	page.Redirects = r.redirects
+}
@@ -0,0 +1,351 @@
+package fetchurl
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// RobotsOff ignores robots.txt.
+	RobotsOff = "off"
+	// RobotsCrawl applies robots.txt to the pages Crawl visits.
+	RobotsCrawl = "crawl"
+	// RobotsAll applies robots.txt to every page fetch and download.
+	RobotsAll = "all"
+
+	// DefaultRobotsUserAgent is the product token matched against the
+	// User-agent lines of robots.txt.
+	DefaultRobotsUserAgent = "mcpfurl"
+
+	// robotsMaxAge is how long a robots.txt is cached (RFC 9309 asks for
+	// no more than 24 hours).
+	robotsMaxAge = 24 * time.Hour
+	// robotsMaxBytes is the most of a robots.txt that is parsed.
+	robotsMaxBytes = 500 * 1024
+	// maxCrawlDelay caps the Crawl-delay that is honored, so a large value
+	// doesn't hold a request until it times out.
+	maxCrawlDelay = 30 * time.Second
+)
+
+// ValidRobotsMode reports whether mode is a known robots.txt mode.
+func ValidRobotsMode(mode string) bool {
+	switch mode {
+	case RobotsOff, RobotsCrawl, RobotsAll:
+		return true
+	}
+	return false
+}
+
+// RobotsDeniedError is returned when robots.txt disallows a URL.
+type RobotsDeniedError struct {
+	URL       string
+	UserAgent string
+}
+
+func (e *RobotsDeniedError) Error() string {
+	return fmt.Sprintf("URL %q is denied by robots.txt (user agent %s)", e.URL, e.UserAgent)
+}
+
+// robotsRule is one Allow or Disallow line.
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+// robotsRules are the rules of a robots.txt that apply to our user agent.
+type robotsRules struct {
+	rules       []robotsRule
+	crawlDelay  time.Duration
+	disallowAll bool // robots.txt couldn't be fetched (5xx or network error)
+}
+
+// parseRobots returns the rules of the groups in a robots.txt that match
+// userAgent, or of the "*" group if none do. userAgent is a product token
+// (ex: "mcpfurl"), matched case-insensitively.
+func parseRobots(body []byte, userAgent string) *robotsRules {
+	userAgent = strings.ToLower(userAgent)
+
+	type group struct {
+		agents []string
+		rules  []robotsRule
+		delay  time.Duration
+	}
+	var groups []*group
+	var cur *group
+	inAgents := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// consecutive User-agent lines share a group
+			if !inAgents {
+				cur = &group{}
+				groups = append(groups, cur)
+			}
+			cur.agents = append(cur.agents, strings.ToLower(value))
+			inAgents = true
+		case "allow", "disallow":
+			inAgents = false
+			if cur == nil || value == "" {
+				// an empty Disallow allows everything
+				continue
+			}
+			cur.rules = append(cur.rules, robotsRule{allow: key == "allow", pattern: value})
+		case "crawl-delay":
+			inAgents = false
+			if cur == nil {
+				continue
+			}
+			if secs, err := strconv.ParseFloat(value, 64); err == nil && secs > 0 {
+				cur.delay = time.Duration(secs * float64(time.Second))
+			}
+		default:
+			// sitemap and unknown lines don't end the group
+		}
+	}
+
+	out := &robotsRules{}
+	for _, want := range []string{userAgent, "*"} {
+		matched := false
+		for _, g := range groups {
+			for _, agent := range g.agents {
+				if agent == want {
+					out.rules = append(out.rules, g.rules...)
+					out.crawlDelay = max(out.crawlDelay, g.delay)
+					matched = true
+					break
+				}
+			}
+		}
+		if matched {
+			break
+		}
+	}
+	return out
+}
+
+// allowed reports whether the path (with its query) may be fetched. The
+// longest matching rule wins, and Allow wins a tie.
+func (r *robotsRules) allowed(path string) bool {
+	if r.disallowAll {
+		return false
+	}
+	if path == "" {
+		path = "/"
+	}
+	if path == "/robots.txt" {
+		return true
+	}
+	best := -1
+	allow := true
+	for _, rule := range r.rules {
+		if !robotsMatch(rule.pattern, path) {
+			continue
+		}
+		if n := len(rule.pattern); n > best || (n == best && rule.allow) {
+			best = n
+			allow = rule.allow
+		}
+	}
+	return allow
+}
+
+// robotsMatch matches a robots.txt path pattern, where * matches any
+// characters and a trailing $ anchors the end of the path.
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	parts := strings.Split(pattern, "*")
+
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+	for i, part := range parts[1:] {
+		if i == len(parts)-2 && anchored {
+			// the last part must end the path
+			return len(path)-len(part) >= pos && strings.HasSuffix(path, part)
+		}
+		idx := strings.Index(path[pos:], part)
+		if idx < 0 {
+			return false
+		}
+		pos += idx + len(part)
+	}
+	return !anchored || pos == len(path)
+}
+
+// robotsChecker fetches and caches robots.txt per origin, and spaces out
+// requests to hosts that ask for a Crawl-delay.
+type robotsChecker struct {
+	userAgent string
+	client    *http.Client
+	cache     *CacheDB
+	logger    *slog.Logger
+
+	mu      sync.Mutex
+	entries map[string]*robotsEntry
+	next    map[string]time.Time // earliest time of the next request, by origin
+}
+
+type robotsEntry struct {
+	rules   *robotsRules
+	fetched time.Time
+}
+
+func newRobotsChecker(userAgent string, client *http.Client, cache *CacheDB, logger *slog.Logger) *robotsChecker {
+	return &robotsChecker{
+		userAgent: userAgent,
+		client:    client,
+		cache:     cache,
+		logger:    logger,
+		entries:   map[string]*robotsEntry{},
+		next:      map[string]time.Time{},
+	}
+}
+
+// checkRobots returns a RobotsDeniedError if robots.txt disallows targetURL,
+// and otherwise waits out the host's Crawl-delay. Crawl sets crawling for the
+// pages it visits; in RobotsAll mode those are checked by the fetch itself
+// instead.
+func (w *WebFetcher) checkRobots(ctx context.Context, targetURL string, crawling bool) error {
+	switch {
+	case w.robots == nil:
+		return nil
+	case w.opts.RobotsMode == RobotsCrawl && !crawling:
+		return nil
+	case w.opts.RobotsMode == RobotsAll && crawling:
+		return nil
+	}
+
+	u, err := url.Parse(targetURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil
+	}
+	origin := u.Scheme + "://" + strings.ToLower(u.Host)
+
+	rules := w.robots.rulesFor(ctx, origin)
+	if !rules.allowed(u.RequestURI()) {
+		return &RobotsDeniedError{URL: targetURL, UserAgent: w.robots.userAgent}
+	}
+	if rules.crawlDelay > 0 {
+		return w.robots.wait(ctx, origin, min(rules.crawlDelay, maxCrawlDelay))
+	}
+	return nil
+}
+
+// rulesFor returns the robots.txt rules of an origin, from memory, the
+// CacheDB or the site.
+func (c *robotsChecker) rulesFor(ctx context.Context, origin string) *robotsRules {
+	c.mu.Lock()
+	entry := c.entries[origin]
+	c.mu.Unlock()
+	if entry != nil && time.Since(entry.fetched) < robotsMaxAge {
+		return entry.rules
+	}
+
+	var status int
+	var body []byte
+	fetched := time.Now()
+	cached := false
+	if c.cache != nil {
+		var err error
+		if status, body, fetched, cached, err = c.cache.GetRobotsTxt(ctx, origin, robotsMaxAge); err != nil {
+			c.logger.Debug("robots.txt cache get failed", "origin", origin, "error", err)
+		}
+	}
+	if !cached {
+		fetched = time.Now()
+		status, body = c.fetch(ctx, origin)
+		c.logger.Debug("Fetched robots.txt", "origin", origin, "status", status)
+		// network errors (status 0) are retried on the next request
+		if c.cache != nil && status != 0 {
+			if err := c.cache.PutRobotsTxt(ctx, origin, status, body); err != nil {
+				c.logger.Debug("robots.txt cache put failed", "origin", origin, "error", err)
+			}
+		}
+	}
+
+	var rules *robotsRules
+	switch {
+	case status >= 200 && status < 300:
+		rules = parseRobots(body, c.userAgent)
+	case status >= 400 && status < 500:
+		// no robots.txt: everything is allowed
+		rules = &robotsRules{}
+	default:
+		// the site is unreachable or failing, so assume it is off limits
+		rules = &robotsRules{disallowAll: true}
+	}
+
+	if status != 0 {
+		c.mu.Lock()
+		c.entries[origin] = &robotsEntry{rules: rules, fetched: fetched}
+		c.mu.Unlock()
+	}
+	return rules
+}
+
+// fetch downloads origin's robots.txt. Redirects are followed. The status is
+// 0 if the request failed.
+func (c *robotsChecker) fetch(ctx context.Context, origin string) (int, []byte) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, origin+"/robots.txt", nil)
+	if err != nil {
+		return 0, nil
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, nil
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, robotsMaxBytes))
+	if err != nil {
+		return 0, nil
+	}
+	return resp.StatusCode, body
+}
+
+// wait blocks until delay has passed since the previous request to origin.
+func (c *robotsChecker) wait(ctx context.Context, origin string, delay time.Duration) error {
+	c.mu.Lock()
+	now := time.Now()
+	at := c.next[origin]
+	if at.Before(now) {
+		at = now
+	}
+	c.next[origin] = at.Add(delay)
+	c.mu.Unlock()
+
+	if d := time.Until(at); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return nil
+}
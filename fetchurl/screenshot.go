@@ -0,0 +1,269 @@
+package fetchurl
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+const (
+	// DefaultScreenshotMaxDimension caps the width and height of a
+	// screenshot in pixels; larger captures are scaled down to fit.
+	DefaultScreenshotMaxDimension = 2000
+	// DefaultScreenshotMaxBytes caps the size of an encoded screenshot.
+	DefaultScreenshotMaxBytes = 4 * 1024 * 1024
+	// DefaultScreenshotQuality is the JPEG/WebP quality.
+	DefaultScreenshotQuality = 80
+
+	// maxScreenshotAspect cuts full-page captures off at this many times
+	// their width, so long pages aren't scaled down to a sliver.
+	maxScreenshotAspect = 4
+	// screenshotShrinkTries is how many times a capture that is over the
+	// byte limit is retried at a smaller scale.
+	screenshotShrinkTries = 5
+)
+
+// ScreenshotOptions control FetchURLScreenshot. Zero values use the browser
+// (or matching FetchProfile) viewport and the defaults above.
+type ScreenshotOptions struct {
+	Width        int     // viewport width in CSS pixels
+	Height       int     // viewport height in CSS pixels
+	Scale        float64 // device scale factor (ex: 2 for a "retina" capture)
+	FullPage     bool    // capture the whole page instead of the viewport
+	Selector     string  // capture only the element matching this CSS selector
+	Format       string  // png (default), jpeg or webp
+	Quality      int     // 1-100, for jpeg and webp
+	MaxDimension int     // max width/height in pixels (default: DefaultScreenshotMaxDimension)
+	MaxBytes     int     // max encoded size (default: DefaultScreenshotMaxBytes)
+}
+
+// Screenshot is an encoded page capture.
+type Screenshot struct {
+	Data     []byte
+	MimeType string
+	Width    int // in pixels
+	Height   int
+}
+
+// Validate checks the options for values Chrome would reject.
+func (o ScreenshotOptions) Validate() error {
+	switch strings.ToLower(o.Format) {
+	case "", "png", "jpeg", "jpg", "webp":
+	default:
+		return fmt.Errorf("unknown screenshot format %q (expected png, jpeg or webp)", o.Format)
+	}
+	if o.Quality < 0 || o.Quality > 100 {
+		return fmt.Errorf("quality must be between 1 and 100")
+	}
+	if o.Width < 0 || o.Height < 0 || o.Width > 10000 || o.Height > 10000 {
+		return fmt.Errorf("viewport width and height must be between 1 and 10000")
+	}
+	if o.Scale < 0 || o.Scale > 4 {
+		return fmt.Errorf("scale must be between 0 and 4")
+	}
+	if o.FullPage && o.Selector != "" {
+		return fmt.Errorf("full_page and selector can't be used together")
+	}
+	if o.MaxDimension < 0 || o.MaxBytes < 0 {
+		return fmt.Errorf("max dimension and max bytes can't be negative")
+	}
+	return nil
+}
+
+func (o ScreenshotOptions) format() (page.CaptureScreenshotFormat, string) {
+	switch strings.ToLower(o.Format) {
+	case "jpeg", "jpg":
+		return page.CaptureScreenshotFormatJpeg, "image/jpeg"
+	case "webp":
+		return page.CaptureScreenshotFormatWebp, "image/webp"
+	}
+	return page.CaptureScreenshotFormatPng, "image/png"
+}
+
+// FetchURLScreenshot renders targetURL in headless Chrome and captures the
+// viewport, the whole page or a single element. The capture is scaled down to
+// fit MaxDimension, and shrunk further if it is over MaxBytes, so it can be
+// handed to a client as is.
+func (w *WebFetcher) FetchURLScreenshot(ctx context.Context, targetURL string, opts ScreenshotOptions) (*Screenshot, error) {
+
+	// check the URL policy first
+	if err := w.checkPolicy(ctx, targetURL); err != nil {
+		return nil, err
+	}
+	if err := w.checkRobots(ctx, targetURL, false); err != nil {
+		return nil, err
+	}
+	if err := w.checkAddress(ctx, targetURL); err != nil {
+		return nil, err
+	}
+
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	format, mimeType := opts.format()
+	quality := opts.Quality
+	if quality == 0 {
+		quality = DefaultScreenshotQuality
+	}
+	maxDim := opts.MaxDimension
+	if maxDim == 0 {
+		maxDim = DefaultScreenshotMaxDimension
+	}
+	maxBytes := opts.MaxBytes
+	if maxBytes == 0 {
+		maxBytes = DefaultScreenshotMaxBytes
+	}
+
+	profile := w.profileFor(targetURL)
+	wait := w.waitStrategyFor(targetURL)
+	if wait.IsZero() && profile != nil {
+		wait = profile.Wait
+	}
+
+	var shot *Screenshot
+
+	err := w.withTab(ctx, func(tabCtx context.Context) error {
+		tabCtx, cancel := context.WithTimeout(tabCtx, time.Duration(w.opts.PageLoadTimeoutSecs)*time.Second)
+		defer cancel()
+
+		waiter := newReadinessWaiter(wait, DefaultWaitTimeout, w.opts.Logger)
+
+		var clip page.Viewport
+		var dpr float64
+		blocker := w.newRequestBlocker(ctx, ResourceBlocking{}, profile)
+		err := chromedp.Run(tabCtx,
+			stealthSetup(),
+			profile.setup(targetURL),
+			blocker.setup(),
+			screenshotViewport(opts),
+			waiter.setup(),
+			chromedp.Navigate(targetURL),
+			waiter.wait(),
+			chromedp.Evaluate(`window.devicePixelRatio`, &dpr),
+			screenshotClip(opts, &clip),
+		)
+		if blockedErr := blocker.err(); blockedErr != nil {
+			return blockedErr
+		}
+		if err != nil {
+			return err
+		}
+
+		if clip.Width < 1 || clip.Height < 1 {
+			return fmt.Errorf("nothing to capture (%.0fx%.0f)", clip.Width, clip.Height)
+		}
+		clip.Height = math.Min(clip.Height, clip.Width*maxScreenshotAspect)
+		if dpr <= 0 {
+			dpr = 1
+		}
+		clip.Scale = math.Min(1, float64(maxDim)/(math.Max(clip.Width, clip.Height)*dpr))
+
+		for try := 1; ; try++ {
+			capture := page.CaptureScreenshot().
+				WithFormat(format).
+				WithClip(&clip).
+				WithCaptureBeyondViewport(true)
+			if format != page.CaptureScreenshotFormatPng {
+				capture = capture.WithQuality(int64(quality))
+			}
+			data, err := capture.Do(tabCtx)
+			if err != nil {
+				return fmt.Errorf("capturing screenshot: %w", err)
+			}
+			if len(data) <= maxBytes {
+				shot = &Screenshot{
+					Data:     data,
+					MimeType: mimeType,
+					Width:    int(math.Round(clip.Width * clip.Scale * dpr)),
+					Height:   int(math.Round(clip.Height * clip.Scale * dpr)),
+				}
+				return nil
+			}
+			if try == screenshotShrinkTries {
+				return fmt.Errorf("screenshot is %d bytes, over the %d bytes limit", len(data), maxBytes)
+			}
+			w.opts.Logger.Debug("Screenshot over size limit, shrinking", "bytes", len(data), "limit", maxBytes, "scale", clip.Scale)
+			clip.Scale *= 0.75
+			quality = max(quality-15, 30)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return shot, nil
+}
+
+// screenshotViewport overrides the viewport size and device scale factor
+// when any are set.
+func screenshotViewport(opts ScreenshotOptions) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if opts.Width == 0 && opts.Height == 0 && opts.Scale == 0 {
+			return nil
+		}
+		var size []int
+		if err := chromedp.Evaluate(`[window.innerWidth, window.innerHeight]`, &size).Do(ctx); err != nil {
+			return err
+		}
+		if len(size) != 2 {
+			return fmt.Errorf("unable to get the viewport size")
+		}
+		width, height := size[0], size[1]
+		if opts.Width > 0 {
+			width = opts.Width
+		}
+		if opts.Height > 0 {
+			height = opts.Height
+		}
+		scale := opts.Scale
+		if scale == 0 {
+			scale = 1
+		}
+		return emulation.SetDeviceMetricsOverride(int64(width), int64(height), scale, false).Do(ctx)
+	})
+}
+
+// screenshotClip sets clip to the area to capture, in CSS pixels.
+func screenshotClip(opts ScreenshotOptions, clip *page.Viewport) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if opts.Selector != "" {
+			var rect struct {
+				Found  bool    `json:"found"`
+				X      float64 `json:"x"`
+				Y      float64 `json:"y"`
+				Width  float64 `json:"width"`
+				Height float64 `json:"height"`
+			}
+			if err := chromedp.Evaluate(fmt.Sprintf(`(() => {
+				const el = document.querySelector(%q);
+				if (!el) return {found: false};
+				const r = el.getBoundingClientRect();
+				return {found: true, x: r.left + window.scrollX, y: r.top + window.scrollY, width: r.width, height: r.height};
+			})()`, opts.Selector), &rect).Do(ctx); err != nil {
+				return err
+			}
+			if !rect.Found {
+				return fmt.Errorf("selector %q not found", opts.Selector)
+			}
+			*clip = page.Viewport{X: rect.X, Y: rect.Y, Width: rect.Width, Height: rect.Height}
+			return nil
+		}
+
+		_, _, _, _, visual, content, err := page.GetLayoutMetrics().Do(ctx)
+		if err != nil {
+			return fmt.Errorf("getting layout metrics: %w", err)
+		}
+		if opts.FullPage {
+			*clip = page.Viewport{Width: content.Width, Height: content.Height}
+		} else {
+			*clip = page.Viewport{X: visual.PageX, Y: visual.PageY, Width: visual.ClientWidth, Height: visual.ClientHeight}
+		}
+		return nil
+	})
+}
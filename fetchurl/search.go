@@ -15,7 +15,8 @@ type SearchEngineConfig struct {
 }
 
 type GenericSearchEngine struct {
-	config *SearchEngineConfig
+	config    *SearchEngineConfig
+	transport http.RoundTripper // nil for http.DefaultTransport
 }
 
 type SearchResult struct {
@@ -62,7 +63,7 @@ func (s *GenericSearchEngine) SearchJSON(ctx context.Context, query string) ([]S
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("User-Agent", "cgmcp-webfetch-search/0.1")
 
-	client := &http.Client{Timeout: 15 * time.Second}
+	client := &http.Client{Timeout: 15 * time.Second, Transport: s.transport}
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -88,7 +88,13 @@ CREATE TABLE IF NOT EXISTS web_cache (
 	fetched_at TIMESTAMP NOT NULL,
 	PRIMARY KEY (url, selector)
 );
-CREATE INDEX IF NOT EXISTS idx_web_cache_fetched ON web_cache(fetched_at);`
+CREATE INDEX IF NOT EXISTS idx_web_cache_fetched ON web_cache(fetched_at);
+CREATE TABLE IF NOT EXISTS robots_cache (
+	origin TEXT PRIMARY KEY,
+	status INTEGER NOT NULL,
+	content BLOB NOT NULL,
+	fetched_at TIMESTAMP NOT NULL
+);`
 
 	if _, execErr := db.Exec(schema); execErr != nil {
 		db.Close()
@@ -114,6 +120,10 @@ func (c *CacheDB) Cleanup() error {
 		c.db.Close()
 		return fmt.Errorf("cleaning web cache: %w", err)
 	}
+	if _, err := c.db.Exec(`DELETE FROM robots_cache WHERE fetched_at < datetime('now', '-1 day')`); err != nil {
+		c.db.Close()
+		return fmt.Errorf("cleaning robots.txt cache: %w", err)
+	}
 	return nil
 
 }
@@ -225,3 +235,45 @@ ON CONFLICT(url, selector) DO UPDATE SET
 `, url, selector, payload, time.Now().UTC())
 	return err
 }
+
+// GetRobotsTxt returns the cached robots.txt response of origin (ex:
+// "https://example.com") if it is newer than maxAge.
+func (c *CacheDB) GetRobotsTxt(ctx context.Context, origin string, maxAge time.Duration) (status int, body []byte, fetched time.Time, ok bool, err error) {
+	if c == nil || c.db == nil {
+		return 0, nil, fetched, false, fmt.Errorf("cache not initialized")
+	}
+
+	err = c.db.QueryRowContext(ctx, `SELECT status, content, fetched_at FROM robots_cache WHERE origin = ?`, origin).
+		Scan(&status, &body, &fetched)
+	if err == sql.ErrNoRows {
+		return 0, nil, fetched, false, nil
+	}
+	if err != nil {
+		return 0, nil, fetched, false, err
+	}
+
+	if time.Since(fetched) > maxAge {
+		return 0, nil, fetched, false, nil
+	}
+	return status, body, fetched, true, nil
+}
+
+// PutRobotsTxt caches the robots.txt response of origin.
+func (c *CacheDB) PutRobotsTxt(ctx context.Context, origin string, status int, body []byte) error {
+	if c == nil || c.db == nil {
+		return fmt.Errorf("cache not initialized")
+	}
+	if body == nil {
+		body = []byte{}
+	}
+
+	_, err := c.db.ExecContext(ctx, `
+INSERT INTO robots_cache (origin, status, content, fetched_at)
+VALUES (?, ?, ?, ?)
+ON CONFLICT(origin) DO UPDATE SET
+	status = excluded.status,
+	content = excluded.content,
+	fetched_at = excluded.fetched_at
+`, origin, status, body, time.Now().UTC())
+	return err
+}
@@ -3,6 +3,7 @@ package fetchurl
 import (
 	"context"
 	"fmt"
+	"net/http"
 
 	"github.com/openai/openai-go/v3"
 	"github.com/openai/openai-go/v3/option"
@@ -41,6 +42,7 @@ func (w *WebFetcher) SummarizeURL(ctx context.Context, targetURL string, selecto
 	client := openai.NewClient(
 		option.WithAPIKey(w.opts.SummarizeApiKey),
 		option.WithBaseURL(w.opts.SummarizeBaseURL),
+		option.WithHTTPClient(&http.Client{Transport: w.apiTransport}),
 	)
 	w.opts.Logger.Debug(fmt.Sprintf("Sending to LLM: %s [%s]", w.opts.SummarizeBaseURL, w.opts.SummarizeModel))
 
@@ -0,0 +1,127 @@
+package fetchurl
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMaxTabs         = 8
+	DefaultTabQueueTimeout = 60 * time.Second
+)
+
+// tabPool bounds the number of browser tabs that can be open at the same
+// time. Requests that can't get a slot right away wait in FIFO order until a
+// slot is released, the caller's context is done, or the queue timeout
+// elapses (whichever comes first).
+type tabPool struct {
+	mu      sync.Mutex
+	max     int
+	active  int
+	waiters []chan struct{}
+	timeout time.Duration
+	logger  *slog.Logger
+
+	// running stats, reported in the logs
+	waited    int64
+	waitTotal time.Duration
+	waitMax   time.Duration
+}
+
+func newTabPool(max int, timeout time.Duration, logger *slog.Logger) *tabPool {
+	return &tabPool{max: max, timeout: timeout, logger: logger}
+}
+
+// acquire blocks until a tab slot is available. The returned func must be
+// called exactly once to give the slot back.
+func (p *tabPool) acquire(ctx context.Context) (func(), error) {
+	p.mu.Lock()
+	if p.active < p.max && len(p.waiters) == 0 {
+		p.active++
+		p.mu.Unlock()
+		return p.releaseFunc(), nil
+	}
+
+	// slots are handed directly to the next waiter on release, so the
+	// channel is buffered to never block the releasing goroutine.
+	ch := make(chan struct{}, 1)
+	p.waiters = append(p.waiters, ch)
+	depth := len(p.waiters)
+	p.mu.Unlock()
+
+	p.logger.Debug("waiting for browser tab", "max_tabs", p.max, "queue_depth", depth)
+
+	start := time.Now()
+	timer := time.NewTimer(p.timeout)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case <-ch:
+		p.recordWait(time.Since(start))
+		return p.releaseFunc(), nil
+	case <-ctx.Done():
+		err = fmt.Errorf("waiting for browser tab: %w", ctx.Err())
+	case <-timer.C:
+		err = fmt.Errorf("timed out after %s waiting for a browser tab", p.timeout)
+	}
+
+	p.mu.Lock()
+	for i, waiter := range p.waiters {
+		if waiter == ch {
+			p.waiters = append(p.waiters[:i], p.waiters[i+1:]...)
+			depth = len(p.waiters)
+			p.mu.Unlock()
+			p.logger.Warn("gave up waiting for browser tab", "waited", time.Since(start), "queue_depth", depth, "error", err)
+			return nil, err
+		}
+	}
+	p.mu.Unlock()
+
+	// We lost the race: a slot was handed to us right as we gave up. Pass it
+	// along so it isn't leaked.
+	<-ch
+	p.release()
+	return nil, err
+}
+
+func (p *tabPool) releaseFunc() func() {
+	var once sync.Once
+	return func() {
+		once.Do(p.release)
+	}
+}
+
+func (p *tabPool) release() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.waiters) > 0 {
+		next := p.waiters[0]
+		p.waiters = p.waiters[1:]
+		next <- struct{}{}
+		return
+	}
+	p.active--
+}
+
+func (p *tabPool) recordWait(d time.Duration) {
+	p.mu.Lock()
+	p.waited++
+	p.waitTotal += d
+	if d > p.waitMax {
+		p.waitMax = d
+	}
+	waited, avg, max, depth := p.waited, p.waitTotal/time.Duration(p.waited), p.waitMax, len(p.waiters)
+	p.mu.Unlock()
+
+	p.logger.Info("acquired browser tab after waiting",
+		"waited", d,
+		"queue_depth", depth,
+		"total_waits", waited,
+		"avg_wait", avg,
+		"max_wait", max,
+	)
+}
@@ -1,41 +1,78 @@
 package fetchurl
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"regexp"
 	"strings"
 )
 
-// ensureURLAllowed enforces allow/deny lists for outbound HTTP requests.
-func ensureURLAllowed(target string, allowList, denyList []string) (bool, error) {
-	// fmt.Printf("checking: %s\nallow: %s\ndeny: %s\n", target, allowList, denyList)
+// maxRedirects is how many redirects the HTTP clients follow.
+const maxRedirects = 10
 
+// RedirectDeniedError is returned when a fetch was redirected (or navigated
+// by the page) from an allowed URL to one the URL policy rejects.
+type RedirectDeniedError struct {
+	From string
+	To   string // the blocked hop
+	Err  error  // the policy error for To
+}
+
+func (e *RedirectDeniedError) Error() string {
+	return fmt.Sprintf("redirect from %q to %q is not allowed: %v", e.From, e.To, e.Err)
+}
+
+func (e *RedirectDeniedError) Unwrap() error {
+	return e.Err
+}
+
+// checkPolicy returns the policy error for target, or nil if the URL policy,
+// and the caller's policy if ctx has one, let the caller of ctx fetch it.
+func (w *WebFetcher) checkPolicy(ctx context.Context, target string) error {
 	target = strings.TrimSpace(target)
 	if target == "" {
-		return false, fmt.Errorf("missing URL")
+		return fmt.Errorf("missing URL")
 	}
+	for _, p := range []*URLPolicy{w.policy, callerPolicy(ctx)} {
+		if p == nil || p.empty {
+			continue
+		}
+		d := p.Evaluate(target)
+		switch {
+		case d.Action == PolicyAllow:
+		case d.Action == PolicyRequireAuth && callerAuthenticated(ctx):
+		default:
+			return &PolicyDeniedError{URL: target, Decision: d}
+		}
+	}
+	return nil
+}
 
-	// check allow first
-	matched, err := matchGlobList(target, allowList)
-	if err != nil {
-		return false, err
+// checkRedirect is the http.Client CheckRedirect of the fetching clients: it
+// applies the URL policy to every hop.
+func (w *WebFetcher) checkRedirect(next *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
 	}
-	if matched {
-		return true, nil
+	if err := w.checkPolicy(next.Context(), next.URL.String()); err != nil {
+		return &RedirectDeniedError{From: via[len(via)-1].URL.String(), To: next.URL.String(), Err: err}
 	}
+	return nil
+}
 
-	if matched, err := matchGlobList(target, denyList); err != nil {
-		return false, err
-	} else if matched {
-		return false, fmt.Errorf("URL %q is denied by policy", target)
+// checkFinalURL applies the URL policy to the URL a fetch ended up at,
+// in case it got there without a redirect being checked (ex: a page that
+// navigated itself with JavaScript).
+func (w *WebFetcher) checkFinalURL(ctx context.Context, targetURL, currentURL string) error {
+	if currentURL == targetURL || !strings.HasPrefix(currentURL, "http") {
+		// "", about:blank and the like
+		return nil
 	}
-
-	// if there is no set allow list, then we assume it is "*"
-	if len(allowList) == 0 {
-		return true, nil
+	if err := w.checkPolicy(ctx, currentURL); err != nil {
+		return &RedirectDeniedError{From: targetURL, To: currentURL, Err: err}
 	}
-
-	return false, fmt.Errorf("URL %q is denied by default", target)
+	return nil
 }
 
 func matchGlobList(target string, patterns []string) (bool, error) {
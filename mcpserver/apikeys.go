@@ -0,0 +1,357 @@
+package mcpserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mbreese/mcpfurl/fetchurl"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// APIKey is one caller of the HTTP server, with its own tools, URL globs and
+// daily quotas.
+type APIKey struct {
+	Name   string
+	SHA256 string // hex SHA-256 of the key, which is sent as a bearer token
+	// Tools are the MCP tools the key may call, and their REST endpoints
+	// (ex: web_fetch is also /api/fetch). Empty allows them all.
+	Tools           []string
+	AllowedURLGlobs []string // layered on the server's URL policy
+	DenyURLGlobs    []string
+	RequestsPerDay  int   // 0 = unlimited
+	BytesPerDay     int64 // bytes of responses sent to the key, 0 = unlimited
+}
+
+// HashAPIKey returns the hex SHA-256 of key, for APIKey.SHA256.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// masterKeyName is the name the master key is logged with.
+const masterKeyName = "master"
+
+// restTools are the tools behind the REST endpoints, for the API key scopes.
+var restTools = map[string]string{
+	"/api/fetch":         "web_fetch",
+	"/api/summary":       "web_summary",
+	"/api/image":         "image_fetch",
+	"/api/browser-image": "browser_image_fetch",
+	"/api/file":          "file_download",
+	"/api/browser-file":  "browser_file_download",
+	"/api/pdf":           "pdf_fetch",
+	"/api/page-pdf":      "page_pdf",
+	"/api/screenshot":    "web_screenshot",
+	"/api/search":        "web_search",
+}
+
+// apiCaller is an API key (or the master key) and its usage today.
+type apiCaller struct {
+	name     string
+	hash     []byte          // SHA-256 of the key
+	tools    map[string]bool // nil allows every tool
+	policy   *fetchurl.URLPolicy
+	maxReqs  int
+	maxBytes int64
+
+	mu       sync.Mutex
+	day      string // UTC date the counters are for
+	requests int
+	bytes    int64
+}
+
+// keyring holds the keys the HTTP server accepts.
+type keyring struct {
+	masterKey string
+	master    *apiCaller // the caller of the master key, if there is one
+	callers   []*apiCaller
+	jwt       *jwtVerifier // JWT access tokens, if OAuth is set up
+}
+
+// newKeyring returns the keys the HTTP server accepts, or nil if it is open
+// to everyone (no master key, API keys or OAuth).
+func newKeyring(masterKey string, keys []APIKey, jwt *jwtVerifier) (*keyring, error) {
+	if masterKey == "" && len(keys) == 0 && jwt == nil {
+		return nil, nil
+	}
+	k := &keyring{masterKey: masterKey, jwt: jwt}
+	if masterKey != "" {
+		k.master = &apiCaller{name: masterKeyName}
+	}
+	names := map[string]bool{}
+	for i, key := range keys {
+		name := strings.TrimSpace(key.Name)
+		if name == "" {
+			return nil, fmt.Errorf("API key #%d has no name", i+1)
+		}
+		if names[name] || name == masterKeyName {
+			return nil, fmt.Errorf("API key %q: the name is already used", name)
+		}
+		names[name] = true
+		hash, err := hex.DecodeString(strings.TrimSpace(key.SHA256))
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("API key %q: key_sha256 must be a hex SHA-256 (see mcpfurl hash-key)", name)
+		}
+		policy, err := fetchurl.NewURLPolicy(nil, key.AllowedURLGlobs, key.DenyURLGlobs, "")
+		if err != nil {
+			return nil, fmt.Errorf("API key %q: %w", name, err)
+		}
+		c := &apiCaller{
+			name:     name,
+			hash:     hash,
+			policy:   policy,
+			maxReqs:  key.RequestsPerDay,
+			maxBytes: key.BytesPerDay,
+		}
+		for _, tool := range key.Tools {
+			tool = strings.TrimSpace(tool)
+			if tool == "" {
+				continue
+			}
+			if c.tools == nil {
+				c.tools = map[string]bool{}
+			}
+			c.tools[tool] = true
+		}
+		k.callers = append(k.callers, c)
+	}
+	return k, nil
+}
+
+// errNoToken is the error of requests without a bearer token.
+var errNoToken = errors.New("no bearer token")
+
+// lookup returns the caller of an Authorization header: the master key, an
+// API key or a JWT access token. It returns errNoToken without a token, and
+// an error wrapping errInvalidToken for tokens that aren't accepted.
+func (k *keyring) lookup(ctx context.Context, authorization string) (*apiCaller, error) {
+	scheme, token, _ := strings.Cut(authorization, " ")
+	if !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return nil, errNoToken
+	}
+	if k.master != nil && subtle.ConstantTimeCompare([]byte(token), []byte(k.masterKey)) == 1 {
+		return k.master, nil
+	}
+	sum := sha256.Sum256([]byte(token))
+	var found *apiCaller
+	for _, c := range k.callers {
+		// no early return, so the time taken doesn't tell which key matched
+		if subtle.ConstantTimeCompare(sum[:], c.hash) == 1 {
+			found = c
+		}
+	}
+	if found != nil {
+		return found, nil
+	}
+	if k.jwt != nil && strings.Count(token, ".") == 2 {
+		claims, err := k.jwt.verify(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		return k.jwt.caller(claims), nil
+	}
+	return nil, fmt.Errorf("%w: unknown key", errInvalidToken)
+}
+
+// allows reports whether the caller may use tool.
+func (c *apiCaller) allows(tool string) bool {
+	return c.tools == nil || c.tools[tool]
+}
+
+// QuotaExceededError is returned when an API key has used up its requests or
+// bytes for the day.
+type QuotaExceededError struct {
+	Key   string
+	What  string // "requests" or "bytes"
+	Limit int64
+	Reset time.Time // when the quota resets (midnight UTC)
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("API key %q has used its %d %s for today (resets at %s)", e.Key, e.Limit, e.What, e.Reset.Format(time.RFC3339))
+}
+
+// begin counts a request against the caller's quotas, or returns a
+// QuotaExceededError. The bytes quota is checked before the request, so the
+// response that crosses it is still sent.
+func (c *apiCaller) begin() error {
+	if c.maxReqs <= 0 && c.maxBytes <= 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if day := now.Format(time.DateOnly); day != c.day {
+		c.day, c.requests, c.bytes = day, 0, 0
+	}
+	reset := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	if c.maxReqs > 0 && c.requests >= c.maxReqs {
+		return &QuotaExceededError{Key: c.name, What: "requests", Limit: int64(c.maxReqs), Reset: reset}
+	}
+	if c.maxBytes > 0 && c.bytes >= c.maxBytes {
+		return &QuotaExceededError{Key: c.name, What: "bytes", Limit: c.maxBytes, Reset: reset}
+	}
+	c.requests++
+	return nil
+}
+
+// sent counts the bytes of a response against the caller's quota.
+func (c *apiCaller) sent(n int64) {
+	if c.maxBytes <= 0 {
+		return
+	}
+	c.mu.Lock()
+	c.bytes += n
+	c.mu.Unlock()
+}
+
+// context returns ctx for the caller's fetches: authenticated, with the
+// key's URL globs.
+func (c *apiCaller) context(ctx context.Context) context.Context {
+	ctx = fetchurl.WithAuthenticatedCaller(ctx)
+	if c.policy != nil {
+		ctx = fetchurl.WithCallerPolicy(ctx, c.policy)
+	}
+	return context.WithValue(ctx, apiCallerKey{}, c)
+}
+
+type apiCallerKey struct{}
+
+func callerFromContext(ctx context.Context) *apiCaller {
+	c, _ := ctx.Value(apiCallerKey{}).(*apiCaller)
+	return c
+}
+
+// countingWriter counts the bytes of a REST response.
+type countingWriter struct {
+	http.ResponseWriter
+	status int
+	n      int64
+}
+
+func (w *countingWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// restEndpoint applies the scopes and quotas of the caller's key (set by the
+// auth wrapper) to a REST endpoint, and logs who used it.
+func restEndpoint(path string, next http.HandlerFunc) http.Handler {
+	tool := restTools[path]
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := callerFromContext(r.Context())
+		if c == nil {
+			// no keys configured
+			next(w, r)
+			return
+		}
+		if !c.allows(tool) {
+			logger.Warn("API key not allowed to use tool", slog.String("key", c.name), slog.String("tool", tool))
+			writeJSONError(w, http.StatusForbidden, fmt.Sprintf("API key %q may not use %s", c.name, tool))
+			return
+		}
+		if err := c.begin(); err != nil {
+			logger.Warn("API key over quota", slog.String("key", c.name), slog.String("tool", tool), slog.Any("error", err))
+			if quotaErr, ok := err.(*QuotaExceededError); ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(quotaErr.Reset).Seconds())+1))
+			}
+			writeJSONError(w, http.StatusTooManyRequests, err.Error())
+			return
+		}
+		cw := &countingWriter{ResponseWriter: w, status: http.StatusOK}
+		next(cw, r)
+		c.sent(cw.n)
+		logger.Info("API request", slog.String("key", c.name), slog.String("tool", tool),
+			slog.String("url", r.URL.Query().Get("url")), slog.String("query", r.URL.Query().Get("q")),
+			slog.Int("status", cw.status), slog.Int64("bytes", cw.n))
+	})
+}
+
+// mcpCallers identifies the caller of each MCP request. The stdio client is
+// run by the operator, so it is authenticated (it may fetch the URLs of
+// require_auth policy rules). HTTP callers get the scopes, URL globs and
+// quotas of their key: the tools list only has the tools they may call, and
+// their tool calls are logged with the key's name.
+func mcpCallers(keys *keyring) mcp.Middleware {
+	return func(next mcp.MethodHandler) mcp.MethodHandler {
+		return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+			extra := req.GetExtra()
+			if extra == nil {
+				return next(fetchurl.WithAuthenticatedCaller(ctx), method, req)
+			}
+			if keys == nil || extra.Header == nil {
+				return next(ctx, method, req)
+			}
+			c, err := keys.lookup(ctx, extra.Header.Get("Authorization"))
+			if err != nil {
+				// the auth wrapper let the request through, so the token
+				// expired in between
+				return nil, err
+			}
+			ctx = c.context(ctx)
+
+			switch method {
+			case "tools/list":
+				res, err := next(ctx, method, req)
+				if list, ok := res.(*mcp.ListToolsResult); ok && c.tools != nil {
+					tools := list.Tools[:0:0]
+					for _, t := range list.Tools {
+						if c.allows(t.Name) {
+							tools = append(tools, t)
+						}
+					}
+					list.Tools = tools
+				}
+				return res, err
+			case "tools/call":
+				call, ok := req.(*mcp.CallToolRequest)
+				if !ok {
+					return next(ctx, method, req)
+				}
+				tool := call.Params.Name
+				if !c.allows(tool) {
+					logger.Warn("API key not allowed to use tool", slog.String("key", c.name), slog.String("tool", tool))
+					return nil, fmt.Errorf("API key %q may not use %s", c.name, tool)
+				}
+				if err := c.begin(); err != nil {
+					logger.Warn("API key over quota", slog.String("key", c.name), slog.String("tool", tool), slog.Any("error", err))
+					return nil, err
+				}
+				var args struct {
+					URL   string `json:"url"`
+					Query string `json:"query"`
+				}
+				json.Unmarshal(call.Params.Arguments, &args)
+				res, err := next(ctx, method, req)
+				var n int64
+				if res != nil {
+					if raw, err := json.Marshal(res); err == nil {
+						n = int64(len(raw))
+					}
+				}
+				c.sent(n)
+				logger.Info("MCP tool call", slog.String("key", c.name), slog.String("tool", tool),
+					slog.String("url", args.URL), slog.String("query", args.Query), slog.Int64("bytes", n))
+				return res, err
+			}
+			return next(ctx, method, req)
+		}
+	}
+}
@@ -2,9 +2,9 @@ package mcpserver
 
 import (
 	"context"
-	"crypto/subtle"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html"
 	"log"
@@ -12,6 +12,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -20,7 +21,14 @@ import (
 )
 
 type WebFetchParams struct {
-	URL string `json:"url" jsonschema:"The URL of the webpage to fetch"`
+	URL              string                `json:"url" jsonschema:"The URL of the webpage to fetch"`
+	Wait             string                `json:"wait,omitempty" jsonschema:"Optional page readiness strategy: load, network-idle[:500ms], selector:<css>, js:<expression>, delay:<duration> or dom-quiet[:500ms]"`
+	Actions          []fetchurl.PageAction `json:"actions,omitempty" jsonschema:"Optional list of actions to run in order before the page is captured, ex: clicking 'Show more' buttons or dismissing cookie banners"`
+	Exclude          []string              `json:"exclude,omitempty" jsonschema:"Optional CSS selectors of elements to remove before conversion, ex: nav, footer, .cookie-banner"`
+	AllowErrorPages  bool                  `json:"allow_error_pages,omitempty" jsonschema:"Return pages served with a 4xx or 5xx status instead of an error"`
+	FlattenFrames    bool                  `json:"flatten_frames,omitempty" jsonschema:"Inline the content of iframes and open shadow roots (ex: embedded docs, web components)"`
+	Block            []string              `json:"block,omitempty" jsonschema:"Optional requests to block while the page loads, replacing the configured list: resource types (image, media, font, stylesheet, script), trackers, policy, or none"`
+	FailOnExceptions bool                  `json:"fail_on_exceptions,omitempty" jsonschema:"Fail if the page throws uncaught JavaScript exceptions before it is ready"`
 }
 type WebSummaryParams struct {
 	URL   string `json:"url" jsonschema:"The URL of the webpage to summarize"`
@@ -33,8 +41,12 @@ type WebSearchParams struct {
 }
 
 type WebFetchOutput struct {
-	Content string `json:"content" jsonschema:"The content of the webpage converted to Markdown format"`
-	Error   string `json:"error,omitempty" jsonschema:"Any error messages"`
+	Content     string                 `json:"content" jsonschema:"The content of the webpage converted to Markdown format"`
+	StatusCode  int                    `json:"status_code,omitempty" jsonschema:"HTTP status code of the page"`
+	Headers     map[string]string      `json:"headers,omitempty" jsonschema:"Response headers of the page (content-type, last-modified, etag)"`
+	Redirects   []fetchurl.RedirectHop `json:"redirects,omitempty" jsonschema:"Redirects followed from the requested URL to the final URL"`
+	Diagnostics []fetchurl.Diagnostic  `json:"diagnostics,omitempty" jsonschema:"Console messages and uncaught JavaScript exceptions logged by the page"`
+	Error       string                 `json:"error,omitempty" jsonschema:"Any error messages"`
 }
 
 type WebSummaryOutput struct {
@@ -77,6 +89,59 @@ type FileFetchOutput struct {
 	Error       string `json:"error,omitempty" jsonschema:"Any error messages"`
 }
 
+type PdfFetchParams struct {
+	URL      string `json:"url" jsonschema:"The URL of the PDF to fetch"`
+	Pages    string `json:"pages,omitempty" jsonschema:"Optional page ranges to convert, ex: 1-3,5,8- (default: all pages)"`
+	MaxChars int    `json:"max_chars,omitempty" jsonschema:"Optional limit on the number of characters returned (default: no limit)"`
+	Browser  bool   `json:"browser,omitempty" jsonschema:"Download the PDF with headless Chrome (bypasses bot detection)"`
+}
+
+type PdfFetchOutput struct {
+	Title     string `json:"title,omitempty" jsonschema:"The document title"`
+	NumPages  int    `json:"num_pages" jsonschema:"The number of pages in the document"`
+	Pages     []int  `json:"pages,omitempty" jsonschema:"The pages that were converted"`
+	Content   string `json:"content" jsonschema:"The text of the PDF as Markdown, with <!-- page N --> markers"`
+	Truncated bool   `json:"truncated,omitempty" jsonschema:"Whether the content was cut off at max_chars"`
+	Error     string `json:"error,omitempty" jsonschema:"Any error messages"`
+}
+
+type PagePdfParams struct {
+	URL             string `json:"url" jsonschema:"The URL of the webpage to print"`
+	PaperSize       string `json:"paper_size,omitempty" jsonschema:"Optional paper size: letter (default), legal, tabloid, ledger, a3, a4 or a5"`
+	Landscape       bool   `json:"landscape,omitempty" jsonschema:"Print in landscape orientation"`
+	Margins         string `json:"margins,omitempty" jsonschema:"Optional margins in inches: one value, or top,right,bottom,left"`
+	PrintBackground bool   `json:"print_background,omitempty" jsonschema:"Include background graphics"`
+	HeaderTemplate  string `json:"header_template,omitempty" jsonschema:"Optional HTML template for the page header"`
+	FooterTemplate  string `json:"footer_template,omitempty" jsonschema:"Optional HTML template for the page footer, ex: <span class=pageNumber></span>"`
+	PageRanges      string `json:"page_ranges,omitempty" jsonschema:"Optional pages to print, ex: 1-5, 8"`
+}
+
+type PagePdfOutput struct {
+	SizeBytes  int    `json:"size_bytes" jsonschema:"The size of the PDF in bytes"`
+	DataBase64 string `json:"data_base64" jsonschema:"Base64 encoded PDF"`
+	Error      string `json:"error,omitempty" jsonschema:"Any error messages"`
+}
+
+type ScreenshotParams struct {
+	URL          string  `json:"url" jsonschema:"The URL of the webpage to capture"`
+	Width        int     `json:"width,omitempty" jsonschema:"Optional viewport width in CSS pixels"`
+	Height       int     `json:"height,omitempty" jsonschema:"Optional viewport height in CSS pixels"`
+	Scale        float64 `json:"scale,omitempty" jsonschema:"Optional device scale factor, ex: 2 for a high-DPI capture"`
+	FullPage     bool    `json:"full_page,omitempty" jsonschema:"Capture the whole page instead of the viewport"`
+	Selector     string  `json:"selector,omitempty" jsonschema:"Optional CSS selector of a single element to capture"`
+	Format       string  `json:"format,omitempty" jsonschema:"Optional image format: png (default), jpeg or webp"`
+	Quality      int     `json:"quality,omitempty" jsonschema:"Optional jpeg/webp quality, 1-100 (default: 80)"`
+	MaxDimension int     `json:"max_dimension,omitempty" jsonschema:"Optional max width/height in pixels; larger captures are scaled down (default: 2000)"`
+}
+
+type ScreenshotOutput struct {
+	MimeType  string `json:"mime_type" jsonschema:"The image MIME type"`
+	Width     int    `json:"width" jsonschema:"The image width in pixels"`
+	Height    int    `json:"height" jsonschema:"The image height in pixels"`
+	SizeBytes int    `json:"size_bytes" jsonschema:"The size of the image in bytes"`
+	Error     string `json:"error,omitempty" jsonschema:"Any error messages"`
+}
+
 type MCPServerOptions struct {
 	Addr           string
 	Port           int
@@ -89,7 +154,9 @@ type MCPServerOptions struct {
 	DisableFetch   bool
 	DisableImage   bool
 	DisableSummary bool
-	EnableAPI      bool // expose REST API endpoints under /api/
+	EnableAPI      bool          // expose REST API endpoints under /api/
+	APIKeys        []APIKey      // accepted besides the MasterKey
+	OAuth          *OAuthOptions // accept JWT access tokens too
 	CrawlResources []CrawlResourceConfig
 }
 
@@ -115,18 +182,74 @@ func fetchPage(ctx context.Context, req *mcp.CallToolRequest, args WebFetchParam
 			},
 		}, &WebFetchOutput{Error: "Missing URL"}, nil
 	}
-	webpage, err := fetcher.FetchURL(ctx, args.URL, "")
+	wait, err := fetchurl.ParseWaitStrategy(args.Wait)
 	if err != nil {
 		return &mcp.CallToolResult{
 			IsError: true,
 			Content: []mcp.Content{
-				&mcp.TextContent{Text: fmt.Sprintf("Error fetching URL: %s => %v", args.URL, err)},
+				&mcp.TextContent{Text: err.Error()},
+			},
+		}, &WebFetchOutput{Error: err.Error()}, nil
+	}
+	if err := fetchurl.ValidatePageActions(args.Actions); err != nil {
+		return &mcp.CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: err.Error()},
+			},
+		}, &WebFetchOutput{Error: err.Error()}, nil
+	}
+	if err := fetchurl.ValidateExcludeSelectors(args.Exclude); err != nil {
+		return &mcp.CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: err.Error()},
+			},
+		}, &WebFetchOutput{Error: err.Error()}, nil
+	}
+	fetchOpts := fetchurl.FetchOptions{
+		Wait:             wait,
+		Actions:          args.Actions,
+		Exclude:          args.Exclude,
+		AllowErrorPages:  args.AllowErrorPages,
+		FlattenFrames:    args.FlattenFrames,
+		FailOnExceptions: args.FailOnExceptions,
+	}
+	if args.Block != nil {
+		blocking, err := fetchurl.ParseResourceBlocking(args.Block)
+		if err != nil {
+			return &mcp.CallToolResult{
+				IsError: true,
+				Content: []mcp.Content{
+					&mcp.TextContent{Text: err.Error()},
+				},
+			}, &WebFetchOutput{Error: err.Error()}, nil
+		}
+		fetchOpts.Blocking = &blocking
+	}
+	webpage, err := fetcher.FetchURLWithOptions(ctx, args.URL, fetchOpts)
+	if err != nil {
+		var exceptionErr *fetchurl.PageExceptionError
+		out := &WebFetchOutput{Error: fmt.Sprintf("Error fetching URL: %s => %v", args.URL, err)}
+		if errors.As(err, &exceptionErr) {
+			out.Diagnostics = exceptionErr.Exceptions
+		}
+		return &mcp.CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: out.Error},
 			},
-		}, &WebFetchOutput{Error: fmt.Sprintf("Error fetching URL: %s => %v", args.URL, err)}, nil
+		}, out, nil
 	}
 
 	if markdown, err := fetcher.WebpageToMarkdownYaml(webpage); err == nil {
-		return nil, &WebFetchOutput{Content: markdown}, nil
+		return nil, &WebFetchOutput{
+			Content:     markdown,
+			StatusCode:  webpage.StatusCode,
+			Headers:     webpage.Headers,
+			Redirects:   webpage.Redirects,
+			Diagnostics: webpage.Diagnostics,
+		}, nil
 	}
 
 	return &mcp.CallToolResult{
@@ -294,6 +417,129 @@ func fetchFile(ctx context.Context, req *mcp.CallToolRequest, args FileFetchPara
 	}, nil
 }
 
+func fetchPdf(ctx context.Context, req *mcp.CallToolRequest, args PdfFetchParams) (*mcp.CallToolResult, *PdfFetchOutput, error) {
+	if args.URL == "" {
+		return &mcp.CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: "Missing URL"},
+			},
+		}, &PdfFetchOutput{Error: "Missing URL"}, nil
+	}
+	if err := fetchurl.ValidatePageRanges(args.Pages); err != nil {
+		return &mcp.CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: err.Error()},
+			},
+		}, &PdfFetchOutput{Error: err.Error()}, nil
+	}
+	if args.Browser && !fetcher.HasBrowser() {
+		return &mcp.CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: "Headless browser is disabled"},
+			},
+		}, &PdfFetchOutput{Error: "Headless browser is disabled"}, nil
+	}
+
+	logger.Info(fmt.Sprintf("Fetching PDF: %s", args.URL))
+	doc, err := fetcher.FetchPDF(ctx, args.URL, args.Browser, fetchurl.PdfOptions{Pages: args.Pages, MaxChars: args.MaxChars})
+	if err != nil {
+		return &mcp.CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: err.Error()},
+			},
+		}, &PdfFetchOutput{Error: err.Error()}, nil
+	}
+
+	return nil, &PdfFetchOutput{
+		Title:     doc.Title,
+		NumPages:  doc.NumPages,
+		Pages:     doc.Pages,
+		Content:   doc.Markdown,
+		Truncated: doc.Truncated,
+	}, nil
+}
+
+func pagePdf(ctx context.Context, req *mcp.CallToolRequest, args PagePdfParams) (*mcp.CallToolResult, *PagePdfOutput, error) {
+	if args.URL == "" {
+		return &mcp.CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: "Missing URL"},
+			},
+		}, &PagePdfOutput{Error: "Missing URL"}, nil
+	}
+
+	logger.Info(fmt.Sprintf("Printing page to PDF: %s", args.URL))
+	data, err := fetcher.FetchURLPDF(ctx, args.URL, fetchurl.PrintOptions{
+		PaperSize:       args.PaperSize,
+		Landscape:       args.Landscape,
+		Margins:         args.Margins,
+		PrintBackground: args.PrintBackground,
+		HeaderTemplate:  args.HeaderTemplate,
+		FooterTemplate:  args.FooterTemplate,
+		PageRanges:      args.PageRanges,
+	})
+	if err != nil {
+		return &mcp.CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: err.Error()},
+			},
+		}, &PagePdfOutput{Error: err.Error()}, nil
+	}
+
+	return nil, &PagePdfOutput{
+		SizeBytes:  len(data),
+		DataBase64: base64.StdEncoding.EncodeToString(data),
+	}, nil
+}
+
+func webScreenshot(ctx context.Context, req *mcp.CallToolRequest, args ScreenshotParams) (*mcp.CallToolResult, *ScreenshotOutput, error) {
+	if args.URL == "" {
+		return &mcp.CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: "Missing URL"},
+			},
+		}, &ScreenshotOutput{Error: "Missing URL"}, nil
+	}
+
+	logger.Info(fmt.Sprintf("Taking screenshot: %s", args.URL))
+	shot, err := fetcher.FetchURLScreenshot(ctx, args.URL, fetchurl.ScreenshotOptions{
+		Width:        args.Width,
+		Height:       args.Height,
+		Scale:        args.Scale,
+		FullPage:     args.FullPage,
+		Selector:     args.Selector,
+		Format:       args.Format,
+		Quality:      args.Quality,
+		MaxDimension: args.MaxDimension,
+	})
+	if err != nil {
+		return &mcp.CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: err.Error()},
+			},
+		}, &ScreenshotOutput{Error: err.Error()}, nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.ImageContent{Data: shot.Data, MIMEType: shot.MimeType},
+		},
+	}, &ScreenshotOutput{
+		MimeType:  shot.MimeType,
+		Width:     shot.Width,
+		Height:    shot.Height,
+		SizeBytes: len(shot.Data),
+	}, nil
+}
+
 func browserFetchFile(ctx context.Context, req *mcp.CallToolRequest, args FileFetchParams) (*mcp.CallToolResult, *FileFetchOutput, error) {
 	if args.URL == "" {
 		return &mcp.CallToolResult{
@@ -346,7 +592,9 @@ func addCrawlResources(server *mcp.Server, fetcher *fetchurl.WebFetcher, crawlCf
 		if timeout < 30*time.Second {
 			timeout = 30 * time.Second
 		}
-		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		// crawl resources are configured by the operator, so they may use
+		// require_auth URLs
+		ctx, cancel := context.WithTimeout(fetchurl.WithAuthenticatedCaller(context.Background()), timeout)
 		pages, err := fetcher.Crawl(ctx, cfg.URL, depth, maxPages, cfg.SameBasePath, cfg.Selector)
 		cancel()
 		if err != nil {
@@ -388,7 +636,7 @@ func addCrawlResources(server *mcp.Server, fetcher *fetchurl.WebFetcher, crawlCf
 	}
 }
 
-func createMCPServer(mcpOpts MCPServerOptions, fetcher *fetchurl.WebFetcher) *mcp.Server {
+func createMCPServer(mcpOpts MCPServerOptions, fetcher *fetchurl.WebFetcher, keys *keyring) *mcp.Server {
 	if mcpOpts.FetchDesc == "" {
 		mcpOpts.FetchDesc = "Fetch a webpage and return the content in Markdown format"
 	}
@@ -402,6 +650,7 @@ func createMCPServer(mcpOpts MCPServerOptions, fetcher *fetchurl.WebFetcher) *mc
 		mcpOpts.SummaryDesc = "Summarize a webpage and return the summary in Markdown format"
 	}
 	server := mcp.NewServer(&mcp.Implementation{Name: "mcpfurl", Version: "v0.0.1"}, nil)
+	server.AddReceivingMiddleware(mcpCallers(keys))
 
 	if !mcpOpts.DisableFetch {
 		mcp.AddTool(server, &mcp.Tool{
@@ -416,10 +665,12 @@ func createMCPServer(mcpOpts MCPServerOptions, fetcher *fetchurl.WebFetcher) *mc
 			Description: mcpOpts.ImageDesc,
 		}, fetchImage)
 
-		mcp.AddTool(server, &mcp.Tool{
-			Name:        "browser_image_fetch",
-			Description: "Download an image using headless Chrome (bypasses bot detection/reCAPTCHA). Returns base64 data.",
-		}, browserFetchImage)
+		if fetcher != nil && fetcher.HasBrowser() {
+			mcp.AddTool(server, &mcp.Tool{
+				Name:        "browser_image_fetch",
+				Description: "Download an image using headless Chrome (bypasses bot detection/reCAPTCHA). Returns base64 data.",
+			}, browserFetchImage)
+		}
 	}
 
 	mcp.AddTool(server, &mcp.Tool{
@@ -428,9 +679,26 @@ func createMCPServer(mcpOpts MCPServerOptions, fetcher *fetchurl.WebFetcher) *mc
 	}, fetchFile)
 
 	mcp.AddTool(server, &mcp.Tool{
-		Name:        "browser_file_download",
-		Description: "Download a file using headless Chrome (bypasses bot detection/redirects). Returns base64 data.",
-	}, browserFetchFile)
+		Name:        "pdf_fetch",
+		Description: "Fetch a PDF (ex: a paper) and return its text as Markdown, with page markers, headings and tables",
+	}, fetchPdf)
+
+	if fetcher != nil && fetcher.HasBrowser() {
+		mcp.AddTool(server, &mcp.Tool{
+			Name:        "browser_file_download",
+			Description: "Download a file using headless Chrome (bypasses bot detection/redirects). Returns base64 data.",
+		}, browserFetchFile)
+
+		mcp.AddTool(server, &mcp.Tool{
+			Name:        "page_pdf",
+			Description: "Render a webpage in headless Chrome and print it to a searchable PDF (for archiving). Returns base64 data.",
+		}, pagePdf)
+
+		mcp.AddTool(server, &mcp.Tool{
+			Name:        "web_screenshot",
+			Description: "Render a webpage in headless Chrome and return a screenshot (viewport, full page or a single element) as an image",
+		}, webScreenshot)
+	}
 
 	if !mcpOpts.DisableSummary {
 		mcp.AddTool(server, &mcp.Tool{
@@ -469,7 +737,7 @@ func StartStdio(opts fetchurl.WebFetcherOptions, mcpOpts MCPServerOptions) {
 		log.Fatalf("ERROR: %v\n", err)
 	}
 	defer fetcher.Stop()
-	server := createMCPServer(mcpOpts, fetcher)
+	server := createMCPServer(mcpOpts, fetcher, nil)
 
 	if err := server.Run(context.Background(), &mcp.StdioTransport{}); err != nil {
 		log.Fatal(err)
@@ -478,31 +746,98 @@ func StartStdio(opts fetchurl.WebFetcherOptions, mcpOpts MCPServerOptions) {
 
 // ── REST API handlers ─────────────────────────────────────────────────────
 
-// apiWebFetch handles GET /api/fetch?url=...
-// Returns the webpage content as markdown.
+// apiWebFetch handles GET /api/fetch?url=...&wait=...&actions=...&exclude=...
+// Returns the webpage content as markdown, with the page's status code,
+// response headers and redirects. 4xx/5xx pages are a 502 error unless
+// allow_error_pages=true.
+// Optional flatten_frames=true: inline iframe and shadow root content.
+// Optional block: requests to block, comma separated or repeated (see web_fetch).
+// Optional har=true: attach a HAR of the browser's network activity, also on
+// errors (for debugging).
+// Optional fail_on_exceptions=true: fail if the page throws uncaught JS
+// exceptions before it is ready.
+// Optional wait: page readiness strategy (see web_fetch).
+// Optional actions: JSON list of page actions (see web_fetch).
+// Optional exclude: CSS selector of elements to remove (repeatable).
 func apiWebFetch(w http.ResponseWriter, r *http.Request) {
 	url := r.URL.Query().Get("url")
 	if url == "" {
 		http.Error(w, `{"error":"missing url parameter"}`, http.StatusBadRequest)
 		return
 	}
+	wait, err := fetchurl.ParseWaitStrategy(r.URL.Query().Get("wait"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	actions, err := fetchurl.ParsePageActions(r.URL.Query().Get("actions"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	exclude := r.URL.Query()["exclude"]
+	if err := fetchurl.ValidateExcludeSelectors(exclude); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	fetchOpts := fetchurl.FetchOptions{
+		Wait:             wait,
+		Actions:          actions,
+		Exclude:          exclude,
+		AllowErrorPages:  r.URL.Query().Get("allow_error_pages") == "true",
+		FlattenFrames:    r.URL.Query().Get("flatten_frames") == "true",
+		FailOnExceptions: r.URL.Query().Get("fail_on_exceptions") == "true",
+	}
+	if block, ok := r.URL.Query()["block"]; ok {
+		blocking, err := fetchurl.ParseResourceBlocking(block)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		fetchOpts.Blocking = &blocking
+	}
+	if r.URL.Query().Get("har") == "true" {
+		fetchOpts.HAR = fetchurl.NewHARRecorder(0)
+		// the profile's headers and cookies are the operator's, not the caller's
+		fetchOpts.HAR.Redact = true
+	}
 	if fetcher == nil {
 		http.Error(w, `{"error":"fetcher not initialized"}`, http.StatusServiceUnavailable)
 		return
 	}
 	logger.Info(fmt.Sprintf("API web_fetch: %s", url))
-	page, err := fetcher.FetchURL(r.Context(), url, "")
+	page, err := fetcher.FetchURLWithOptions(r.Context(), url, fetchOpts)
+	var statusErr *fetchurl.HTTPStatusError
+	var exceptionErr *fetchurl.PageExceptionError
 	if err != nil {
-		writeJSONError(w, http.StatusBadGateway, err.Error())
+		resp := map[string]any{"error": err.Error()}
+		if errors.As(err, &statusErr) {
+			resp["status_code"] = statusErr.StatusCode
+		}
+		if errors.As(err, &exceptionErr) {
+			resp["diagnostics"] = exceptionErr.Exceptions
+		}
+		if fetchOpts.HAR != nil {
+			resp["har"] = fetchOpts.HAR.HAR()
+		}
+		writeJSON(w, http.StatusBadGateway, resp)
 		return
 	}
 	md, _ := fetcher.WebpageToMarkdownYaml(page)
-	writeJSON(w, http.StatusOK, map[string]any{
+	resp := map[string]any{
 		"target_url":  page.TargetURL,
 		"current_url": page.CurrentURL,
 		"title":       page.Title,
+		"status_code": page.StatusCode,
+		"headers":     page.Headers,
+		"redirects":   page.Redirects,
+		"diagnostics": page.Diagnostics,
 		"content":     md,
-	})
+	}
+	if fetchOpts.HAR != nil {
+		resp["har"] = fetchOpts.HAR.HAR()
+	}
+	writeJSON(w, http.StatusOK, resp)
 }
 
 // apiWebSummary handles GET /api/summary?url=...&short=true
@@ -613,6 +948,150 @@ func apiFileDownload(w http.ResponseWriter, r *http.Request) {
 	w.Write(resource.Body)
 }
 
+// apiPdfFetch handles GET /api/pdf?url=...&pages=...&max_chars=...&browser=true
+// Returns the text of the PDF as markdown.
+// Optional pages: page ranges to convert (ex: 1-3,5).
+// Optional max_chars: truncate the content to this many characters.
+// Optional browser: download the PDF with headless Chrome.
+func apiPdfFetch(w http.ResponseWriter, r *http.Request) {
+	url := r.URL.Query().Get("url")
+	if url == "" {
+		http.Error(w, `{"error":"missing url parameter"}`, http.StatusBadRequest)
+		return
+	}
+	maxChars := 0
+	if s := r.URL.Query().Get("max_chars"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			writeJSONError(w, http.StatusBadRequest, "invalid max_chars parameter")
+			return
+		}
+		maxChars = n
+	}
+	if err := fetchurl.ValidatePageRanges(r.URL.Query().Get("pages")); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	useBrowser := r.URL.Query().Get("browser") == "true"
+	if fetcher == nil {
+		http.Error(w, `{"error":"fetcher not initialized"}`, http.StatusServiceUnavailable)
+		return
+	}
+	if useBrowser && !fetcher.HasBrowser() {
+		writeJSONError(w, http.StatusBadRequest, "headless browser is disabled")
+		return
+	}
+	logger.Info(fmt.Sprintf("API pdf_fetch: %s", url))
+	doc, err := fetcher.FetchPDF(r.Context(), url, useBrowser, fetchurl.PdfOptions{Pages: r.URL.Query().Get("pages"), MaxChars: maxChars})
+	if err != nil {
+		writeJSONError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"url":       url,
+		"title":     doc.Title,
+		"num_pages": doc.NumPages,
+		"pages":     doc.Pages,
+		"content":   doc.Markdown,
+		"truncated": doc.Truncated,
+	})
+}
+
+// apiPagePdf handles GET /api/page-pdf?url=...&paper=...&landscape=true&margins=...
+// Renders the page in headless Chrome and returns it printed to PDF.
+// Optional print_background, header_template, footer_template and page_ranges
+// are passed to Chrome's print (see page_pdf).
+func apiPagePdf(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	url := q.Get("url")
+	if url == "" {
+		http.Error(w, `{"error":"missing url parameter"}`, http.StatusBadRequest)
+		return
+	}
+	opts := fetchurl.PrintOptions{
+		PaperSize:       q.Get("paper"),
+		Landscape:       q.Get("landscape") == "true",
+		Margins:         q.Get("margins"),
+		PrintBackground: q.Get("print_background") == "true",
+		HeaderTemplate:  q.Get("header_template"),
+		FooterTemplate:  q.Get("footer_template"),
+		PageRanges:      q.Get("page_ranges"),
+	}
+	if err := opts.Validate(); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if fetcher == nil {
+		http.Error(w, `{"error":"fetcher not initialized"}`, http.StatusServiceUnavailable)
+		return
+	}
+	logger.Info(fmt.Sprintf("API page_pdf: %s", url))
+	data, err := fetcher.FetchURLPDF(r.Context(), url, opts)
+	if err != nil {
+		writeJSONError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Write(data)
+}
+
+// apiScreenshot handles GET /api/screenshot?url=...&width=...&height=...&full_page=true
+// Renders the page in headless Chrome and returns the raw image. Optional
+// scale, selector, format (png, jpeg or webp), quality and max_dimension are
+// the same as for web_screenshot.
+func apiScreenshot(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	url := q.Get("url")
+	if url == "" {
+		http.Error(w, `{"error":"missing url parameter"}`, http.StatusBadRequest)
+		return
+	}
+	opts := fetchurl.ScreenshotOptions{
+		FullPage: q.Get("full_page") == "true",
+		Selector: q.Get("selector"),
+		Format:   q.Get("format"),
+	}
+	for name, dest := range map[string]*int{
+		"width":         &opts.Width,
+		"height":        &opts.Height,
+		"quality":       &opts.Quality,
+		"max_dimension": &opts.MaxDimension,
+	} {
+		if s := q.Get(name); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("invalid %s parameter", name))
+				return
+			}
+			*dest = n
+		}
+	}
+	if s := q.Get("scale"); s != "" {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid scale parameter")
+			return
+		}
+		opts.Scale = f
+	}
+	if err := opts.Validate(); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if fetcher == nil {
+		http.Error(w, `{"error":"fetcher not initialized"}`, http.StatusServiceUnavailable)
+		return
+	}
+	logger.Info(fmt.Sprintf("API web_screenshot: %s", url))
+	shot, err := fetcher.FetchURLScreenshot(r.Context(), url, opts)
+	if err != nil {
+		writeJSONError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", shot.MimeType)
+	w.Write(shot.Data)
+}
+
 // apiBrowserFileDownload handles GET /api/browser-file?url=...&warmup_url=...
 // Uses headless Chrome to bypass bot detection, returns raw binary file.
 // Optional warmup_url: page to navigate to first to establish cookies.
@@ -666,6 +1145,26 @@ func apiWebSearch(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// apiHealth handles GET /health.
+// Reports the browser mode (local, remote or disabled) and whether it is
+// connected. Not authenticated, so it can be used by container health checks.
+func apiHealth(w http.ResponseWriter, r *http.Request) {
+	if fetcher == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "starting"})
+		return
+	}
+	browser := fetcher.BrowserStatus()
+	status := "ok"
+	if browser.Mode != fetchurl.BrowserModeDisabled && !browser.Connected {
+		// the browser is (re)connected on the next request
+		status = "degraded"
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"status":  status,
+		"browser": browser,
+	})
+}
+
 func writeJSON(w http.ResponseWriter, status int, v any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -683,7 +1182,17 @@ func StartHTTP(opts fetchurl.WebFetcherOptions, mcpOpts MCPServerOptions) {
 		logger = slog.New(slog.DiscardHandler)
 	}
 
-	var err error
+	jwt, err := newJWTVerifier(mcpOpts.OAuth)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error setting up OAuth: %v", err))
+		return
+	}
+	keys, err := newKeyring(mcpOpts.MasterKey, mcpOpts.APIKeys, jwt)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error loading API keys: %v", err))
+		return
+	}
+
 	if fetcher, err = fetchurl.NewWebFetcher(opts); err != nil {
 		logger.Error(fmt.Sprintf("Error creating webfetcher: %v", err))
 		return
@@ -694,7 +1203,7 @@ func StartHTTP(opts fetchurl.WebFetcherOptions, mcpOpts MCPServerOptions) {
 	}
 	defer fetcher.Stop()
 
-	server := createMCPServer(mcpOpts, fetcher)
+	server := createMCPServer(mcpOpts, fetcher, keys)
 
 	handler := mcp.NewStreamableHTTPHandler(
 		func(r *http.Request) *mcp.Server {
@@ -703,18 +1212,26 @@ func StartHTTP(opts fetchurl.WebFetcherOptions, mcpOpts MCPServerOptions) {
 	)
 
 	authWrapper := func(next http.Handler) http.Handler {
-		if mcpOpts.MasterKey == "" {
+		if keys == nil {
 			return next
 		}
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			expected := "Bearer " + mcpOpts.MasterKey
-			if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(expected)) != 1 {
-				logger.Warn("Unauthorized request", slog.String("remote_addr", r.RemoteAddr), slog.String("path", r.URL.Path))
-				w.Header().Set("WWW-Authenticate", "Bearer")
+			caller, err := keys.lookup(r.Context(), r.Header.Get("Authorization"))
+			if err != nil {
+				logger.Warn("Unauthorized request", slog.String("remote_addr", r.RemoteAddr), slog.String("path", r.URL.Path), slog.Any("error", err))
+				challenge := "Bearer"
+				if jwt != nil {
+					// tells MCP clients where to get a token (RFC 9728)
+					challenge += fmt.Sprintf(` resource_metadata="%s"`, jwt.metadataURL(r))
+					if errors.Is(err, errInvalidToken) {
+						challenge += `, error="invalid_token"`
+					}
+				}
+				w.Header().Set("WWW-Authenticate", challenge)
 				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 				return
 			}
-			next.ServeHTTP(w, r)
+			next.ServeHTTP(w, r.WithContext(caller.context(r.Context())))
 		})
 	}
 
@@ -723,16 +1240,24 @@ func StartHTTP(opts fetchurl.WebFetcherOptions, mcpOpts MCPServerOptions) {
 		w.Write([]byte("Hello!\n"))
 	})))
 	mux.Handle("/mcp", authWrapper(handler))
+	mux.HandleFunc("/health", apiHealth)
+	if jwt != nil {
+		mux.HandleFunc(protectedResourcePath, jwt.serveMetadata)
+		mux.HandleFunc(protectedResourcePath+"/", jwt.serveMetadata)
+	}
 	// REST API endpoints — same functionality as MCP tools, less protocol overhead.
 	if mcpOpts.EnableAPI {
 		logger.Info("REST API enabled at /api/*")
-		mux.Handle("/api/fetch", authWrapper(http.HandlerFunc(apiWebFetch)))
-		mux.Handle("/api/summary", authWrapper(http.HandlerFunc(apiWebSummary)))
-		mux.Handle("/api/image", authWrapper(http.HandlerFunc(apiImageFetch)))
-		mux.Handle("/api/browser-image", authWrapper(http.HandlerFunc(apiBrowserImageFetch)))
-		mux.Handle("/api/file", authWrapper(http.HandlerFunc(apiFileDownload)))
-		mux.Handle("/api/browser-file", authWrapper(http.HandlerFunc(apiBrowserFileDownload)))
-		mux.Handle("/api/search", authWrapper(http.HandlerFunc(apiWebSearch)))
+		mux.Handle("/api/fetch", authWrapper(restEndpoint("/api/fetch", apiWebFetch)))
+		mux.Handle("/api/summary", authWrapper(restEndpoint("/api/summary", apiWebSummary)))
+		mux.Handle("/api/image", authWrapper(restEndpoint("/api/image", apiImageFetch)))
+		mux.Handle("/api/browser-image", authWrapper(restEndpoint("/api/browser-image", apiBrowserImageFetch)))
+		mux.Handle("/api/file", authWrapper(restEndpoint("/api/file", apiFileDownload)))
+		mux.Handle("/api/browser-file", authWrapper(restEndpoint("/api/browser-file", apiBrowserFileDownload)))
+		mux.Handle("/api/pdf", authWrapper(restEndpoint("/api/pdf", apiPdfFetch)))
+		mux.Handle("/api/page-pdf", authWrapper(restEndpoint("/api/page-pdf", apiPagePdf)))
+		mux.Handle("/api/screenshot", authWrapper(restEndpoint("/api/screenshot", apiScreenshot)))
+		mux.Handle("/api/search", authWrapper(restEndpoint("/api/search", apiWebSearch)))
 	}
 
 	httpServer := &http.Server{
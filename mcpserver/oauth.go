@@ -0,0 +1,526 @@
+package mcpserver
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/oauthex"
+)
+
+// OAuthOptions make the HTTP server an OAuth 2.1 protected resource: it
+// accepts JWT access tokens signed by the authorization server.
+type OAuthOptions struct {
+	Issuer   string // required "iss" of the tokens
+	Audience string // required "aud" of the tokens (default: Resource)
+	JWKS     string // path or http(s):// URL of the authorization server's JWKS
+	// Resource is this server's resource identifier, advertised in the
+	// protected resource metadata (ex: https://mcp.example.com/mcp). By
+	// default it is derived from each request's host.
+	Resource             string
+	AuthorizationServers []string // advertised in the metadata (default: Issuer)
+	// Scopes map the token's scopes to the tools they give access to (and
+	// their REST endpoints). Without them, each scope is a tool name.
+	Scopes    map[string][]string
+	NameClaim string // claim the caller is logged by (default: sub)
+}
+
+// protectedResourcePath is where the protected resource metadata is served
+// (RFC 9728).
+const protectedResourcePath = "/.well-known/oauth-protected-resource"
+
+// jwksRefreshInterval is how often a JWKS URL is fetched again, at most: a
+// token signed by an unknown key only triggers a refresh after it, and so
+// does a failed refresh.
+const jwksRefreshInterval = time.Minute
+
+// A JWKS URL is fetched again when its Cache-Control max-age runs out
+// (jwksDefaultMaxAge without one), but at least every jwksMaxAgeLimit, so
+// keys the authorization server removes stop being trusted.
+const (
+	jwksDefaultMaxAge = time.Hour
+	jwksMaxAgeLimit   = 24 * time.Hour
+)
+
+// jwtClockSkew is how far exp and nbf may be off.
+const jwtClockSkew = 60 * time.Second
+
+// jwtVerifier checks JWT access tokens.
+type jwtVerifier struct {
+	opts   OAuthOptions
+	client *http.Client
+
+	refreshing sync.Mutex // held while the JWKS URL is fetched again
+
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey // by kid ("" for keys without one)
+	algs    map[string]string           // the alg a key is restricted to, by kid
+	fetched time.Time                   // when the JWKS URL was last fetched (or tried)
+	expires time.Time                   // when the JWKS URL must be fetched again
+}
+
+// newJWTVerifier loads the JWKS and returns the verifier, or nil if opts is
+// nil.
+func newJWTVerifier(opts *OAuthOptions) (*jwtVerifier, error) {
+	if opts == nil {
+		return nil, nil
+	}
+	v := &jwtVerifier{opts: *opts, client: &http.Client{Timeout: 10 * time.Second}}
+	if v.opts.Issuer == "" || v.opts.JWKS == "" {
+		return nil, fmt.Errorf("oauth needs an issuer and a jwks")
+	}
+	if v.opts.Audience == "" {
+		v.opts.Audience = v.opts.Resource
+	}
+	if v.opts.Audience == "" {
+		return nil, fmt.Errorf("oauth needs an audience or a resource")
+	}
+	if len(v.opts.AuthorizationServers) == 0 {
+		v.opts.AuthorizationServers = []string{v.opts.Issuer}
+	}
+	if v.opts.NameClaim == "" {
+		v.opts.NameClaim = "sub"
+	}
+	if err := v.loadKeys(context.Background()); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (v *jwtVerifier) remoteJWKS() bool {
+	return strings.HasPrefix(v.opts.JWKS, "http://") || strings.HasPrefix(v.opts.JWKS, "https://")
+}
+
+// loadKeys reads the JWKS file or fetches the JWKS URL, replacing the keys
+// loaded before.
+func (v *jwtVerifier) loadKeys(ctx context.Context) error {
+	var data []byte
+	var err error
+	maxAge := jwksDefaultMaxAge
+	if v.remoteJWKS() {
+		data, maxAge, err = v.fetchJWKS(ctx)
+	} else {
+		data, err = os.ReadFile(v.opts.JWKS)
+	}
+	if err != nil {
+		return fmt.Errorf("error loading JWKS %s: %w", v.opts.JWKS, err)
+	}
+	keys, algs, err := parseJWKS(data)
+	if err != nil {
+		return fmt.Errorf("error loading JWKS %s: %w", v.opts.JWKS, err)
+	}
+	now := time.Now()
+	v.mu.Lock()
+	v.keys, v.algs, v.fetched, v.expires = keys, algs, now, now.Add(maxAge)
+	v.mu.Unlock()
+	return nil
+}
+
+// fetchJWKS downloads the JWKS URL, and returns how long it may be used.
+func (v *jwtVerifier) fetchJWKS(ctx context.Context) ([]byte, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.opts.JWKS, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	return data, jwksMaxAge(resp.Header.Get("Cache-Control")), err
+}
+
+// jwksMaxAge returns how long a JWKS served with cacheControl may be used,
+// between jwksRefreshInterval and jwksMaxAgeLimit.
+func jwksMaxAge(cacheControl string) time.Duration {
+	maxAge := jwksDefaultMaxAge
+	for _, directive := range strings.Split(cacheControl, ",") {
+		name, value, _ := strings.Cut(strings.ToLower(strings.TrimSpace(directive)), "=")
+		switch name {
+		case "no-cache", "no-store":
+			return jwksRefreshInterval
+		case "max-age":
+			if secs, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil {
+				maxAge = time.Duration(secs) * time.Second
+			}
+		}
+	}
+	return min(max(maxAge, jwksRefreshInterval), jwksMaxAgeLimit)
+}
+
+// key returns the key for kid. A JWKS URL is fetched again when it expires,
+// and for keys missing from it (the authorization server may have rotated
+// its keys), at most once per jwksRefreshInterval.
+func (v *jwtVerifier) key(ctx context.Context, kid string) (crypto.PublicKey, string, error) {
+	if v.remoteJWKS() {
+		v.mu.Lock()
+		expired := time.Now().After(v.expires)
+		v.mu.Unlock()
+		if expired {
+			v.refresh(ctx, false)
+		}
+	}
+
+	v.mu.Lock()
+	key, ok := v.lookupKey(kid)
+	alg := v.algs[kid]
+	v.mu.Unlock()
+	if ok {
+		return key, alg, nil
+	}
+	if v.remoteJWKS() {
+		v.refresh(ctx, true)
+		v.mu.Lock()
+		key, ok = v.lookupKey(kid)
+		alg = v.algs[kid]
+		v.mu.Unlock()
+		if ok {
+			return key, alg, nil
+		}
+	}
+	return nil, "", fmt.Errorf("unknown signing key %q", kid)
+}
+
+// refresh fetches the JWKS URL again if it expired or, for a token signed by
+// an unknown key, if it wasn't fetched in the last jwksRefreshInterval.
+// Concurrent callers share one fetch: those that waited for it find it done.
+// If it fails, the keys are kept and it is retried after
+// jwksRefreshInterval.
+func (v *jwtVerifier) refresh(ctx context.Context, unknownKey bool) {
+	v.refreshing.Lock()
+	defer v.refreshing.Unlock()
+
+	v.mu.Lock()
+	due := time.Now().After(v.expires) || (unknownKey && time.Since(v.fetched) > jwksRefreshInterval)
+	v.mu.Unlock()
+	if !due {
+		return
+	}
+	// the fetch is shared, so one caller giving up mustn't cancel it
+	if err := v.loadKeys(context.WithoutCancel(ctx)); err != nil {
+		logger.Warn("JWKS refresh failed", slog.Any("error", err))
+		now := time.Now()
+		v.mu.Lock()
+		v.fetched, v.expires = now, now.Add(jwksRefreshInterval)
+		v.mu.Unlock()
+	}
+}
+
+// lookupKey finds kid, or the only key if the token doesn't name one. v.mu
+// must be held.
+func (v *jwtVerifier) lookupKey(kid string) (crypto.PublicKey, bool) {
+	if key, ok := v.keys[kid]; ok {
+		return key, true
+	}
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// jwtClaims are the claims of an access token that are checked.
+type jwtClaims struct {
+	Issuer    string         `json:"iss"`
+	Audience  jwtAudience    `json:"aud"`
+	Expires   *float64       `json:"exp"`
+	NotBefore *float64       `json:"nbf"`
+	Scope     string         `json:"scope"` // space-separated (RFC 9068)
+	Scp       []string       `json:"scp"`   // the array some servers use instead
+	raw       map[string]any // every claim, for NameClaim
+}
+
+// jwtAudience is a string or an array of strings.
+type jwtAudience []string
+
+func (a *jwtAudience) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*a = []string{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return fmt.Errorf("invalid aud claim")
+	}
+	*a = many
+	return nil
+}
+
+// errInvalidToken is the error of tokens that are malformed, badly signed,
+// expired or not meant for this server.
+var errInvalidToken = errors.New("invalid token")
+
+// verify checks the signature and claims of a token and returns its claims.
+func (v *jwtVerifier) verify(ctx context.Context, token string) (*jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: not a JWT", errInvalidToken)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || json.Unmarshal(headerJSON, &header) != nil {
+		return nil, fmt.Errorf("%w: malformed header", errInvalidToken)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", errInvalidToken)
+	}
+	key, keyAlg, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidToken, err)
+	}
+	if keyAlg != "" && keyAlg != header.Alg {
+		return nil, fmt.Errorf("%w: key %q is for %s, not %s", errInvalidToken, header.Kid, keyAlg, header.Alg)
+	}
+	if err := verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidToken, err)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed payload", errInvalidToken)
+	}
+	claims := &jwtClaims{}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, fmt.Errorf("%w: malformed claims: %v", errInvalidToken, err)
+	}
+	json.Unmarshal(payload, &claims.raw)
+
+	now := time.Now()
+	switch {
+	case claims.Issuer != v.opts.Issuer:
+		return nil, fmt.Errorf("%w: issuer %q is not trusted", errInvalidToken, claims.Issuer)
+	case !slices.Contains(claims.Audience, v.opts.Audience):
+		return nil, fmt.Errorf("%w: token is not for audience %q", errInvalidToken, v.opts.Audience)
+	case claims.Expires == nil:
+		return nil, fmt.Errorf("%w: token has no expiry", errInvalidToken)
+	case now.After(unixTime(*claims.Expires).Add(jwtClockSkew)):
+		return nil, fmt.Errorf("%w: token expired", errInvalidToken)
+	case claims.NotBefore != nil && now.Add(jwtClockSkew).Before(unixTime(*claims.NotBefore)):
+		return nil, fmt.Errorf("%w: token not valid yet", errInvalidToken)
+	}
+	return claims, nil
+}
+
+func unixTime(secs float64) time.Time {
+	return time.Unix(0, int64(secs*float64(time.Second)))
+}
+
+// scopes returns the token's scopes.
+func (c *jwtClaims) scopes() []string {
+	return append(strings.Fields(c.Scope), c.Scp...)
+}
+
+// caller returns the caller of a verified token: named after its NameClaim,
+// with the tools its scopes give access to.
+func (v *jwtVerifier) caller(claims *jwtClaims) *apiCaller {
+	name, _ := claims.raw[v.opts.NameClaim].(string)
+	c := &apiCaller{name: "jwt:" + name, tools: map[string]bool{}}
+	for _, scope := range claims.scopes() {
+		if v.opts.Scopes == nil {
+			c.tools[scope] = true
+			continue
+		}
+		for _, tool := range v.opts.Scopes[scope] {
+			c.tools[tool] = true
+		}
+	}
+	return c
+}
+
+// verifySignature checks a JWS signature. Only asymmetric algorithms are
+// accepted: a shared secret would have to be configured here too.
+func verifySignature(alg string, key crypto.PublicKey, signed, sig []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "PS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "PS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "PS512", "ES512":
+		hash = crypto.SHA512
+	case "EdDSA":
+		k, ok := key.(ed25519.PublicKey)
+		if !ok || !ed25519.Verify(k, signed, sig) {
+			return fmt.Errorf("bad signature")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported alg %q", alg)
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		var err error
+		switch alg[:2] {
+		case "RS":
+			err = rsa.VerifyPKCS1v15(k, hash, digest, sig)
+		case "PS":
+			err = rsa.VerifyPSS(k, hash, digest, sig, nil)
+		default:
+			err = fmt.Errorf("%s needs an EC key", alg)
+		}
+		if err != nil {
+			return fmt.Errorf("bad signature")
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if alg[:2] != "ES" || len(sig) != 2*size {
+			return fmt.Errorf("bad signature")
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return fmt.Errorf("bad signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("%s can't be used with this key", alg)
+}
+
+// parseJWKS returns the public keys of a JWK Set by kid, and the alg each one
+// is restricted to. Keys meant for encryption ("use": "enc") are skipped.
+func parseJWKS(data []byte) (map[string]crypto.PublicKey, map[string]string, error) {
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			Alg string `json:"alg"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+	keys := map[string]crypto.PublicKey{}
+	algs := map[string]string{}
+	for _, k := range set.Keys {
+		if k.Use == "enc" {
+			continue
+		}
+		var key crypto.PublicKey
+		switch k.Kty {
+		case "RSA":
+			n, err1 := base64.RawURLEncoding.DecodeString(k.N)
+			e, err2 := base64.RawURLEncoding.DecodeString(k.E)
+			if err1 != nil || err2 != nil || len(e) > 4 {
+				return nil, nil, fmt.Errorf("invalid RSA key %q", k.Kid)
+			}
+			key = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch k.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				return nil, nil, fmt.Errorf("unsupported curve %q for key %q", k.Crv, k.Kid)
+			}
+			x, err1 := base64.RawURLEncoding.DecodeString(k.X)
+			y, err2 := base64.RawURLEncoding.DecodeString(k.Y)
+			if err1 != nil || err2 != nil {
+				return nil, nil, fmt.Errorf("invalid EC key %q", k.Kid)
+			}
+			ec := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+			if !curve.IsOnCurve(ec.X, ec.Y) {
+				return nil, nil, fmt.Errorf("invalid EC key %q", k.Kid)
+			}
+			key = ec
+		case "OKP":
+			x, err := base64.RawURLEncoding.DecodeString(k.X)
+			if k.Crv != "Ed25519" || err != nil || len(x) != ed25519.PublicKeySize {
+				return nil, nil, fmt.Errorf("unsupported OKP key %q", k.Kid)
+			}
+			key = ed25519.PublicKey(x)
+		default:
+			// symmetric ("oct") and unknown keys can't verify access tokens
+			continue
+		}
+		keys[k.Kid] = key
+		if k.Alg != "" {
+			algs[k.Kid] = k.Alg
+		}
+	}
+	if len(keys) == 0 {
+		return nil, nil, fmt.Errorf("JWKS has no signing keys")
+	}
+	return keys, algs, nil
+}
+
+// resource returns the resource identifier of the server r was sent to.
+func (v *jwtVerifier) resource(r *http.Request) string {
+	if v.opts.Resource != "" {
+		return v.opts.Resource
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + "/mcp"
+}
+
+// metadataURL returns the URL of the protected resource metadata of the
+// server r was sent to, for the WWW-Authenticate header.
+func (v *jwtVerifier) metadataURL(r *http.Request) string {
+	u, err := url.Parse(v.resource(r))
+	if err != nil {
+		return ""
+	}
+	u.Path = protectedResourcePath + strings.TrimSuffix(u.Path, "/")
+	return u.String()
+}
+
+// serveMetadata serves the protected resource metadata, which tells MCP
+// clients where to get a token.
+func (v *jwtVerifier) serveMetadata(w http.ResponseWriter, r *http.Request) {
+	meta := oauthex.ProtectedResourceMetadata{
+		Resource:               v.resource(r),
+		AuthorizationServers:   v.opts.AuthorizationServers,
+		BearerMethodsSupported: []string{"header"},
+		ResourceName:           "mcpfurl",
+	}
+	for scope := range v.opts.Scopes {
+		meta.ScopesSupported = append(meta.ScopesSupported, scope)
+	}
+	slices.Sort(meta.ScopesSupported)
+	writeJSON(w, http.StatusOK, meta)
+}